@@ -2,6 +2,7 @@ package input
 
 import (
 	"bufio"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -63,10 +64,16 @@ func ReadFileLines(name string, useLine func(line string) error) error {
 	}
 	defer file.Close()
 
-	scan := bufio.NewScanner(file)
+	return ReadLines(file, useLine)
+}
+
+// ReadLines calls useLine with every line of r, without the line ending,
+// and stops at the first error useLine or the reader returns.
+func ReadLines(r io.Reader, useLine func(line string) error) error {
+	scan := bufio.NewScanner(r)
 	for scan.Scan() {
 		txt := scan.Text()
-		err = useLine(txt)
+		err := useLine(txt)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,435 @@
+// Package numeral implements positional number systems with any base and any
+// contiguous range of digit values containing zero: standard bases, with
+// digits 0 to base-1, and balanced ones like SNAFU, with digits -2 to 2.
+//
+// Numbers are kept as digit arrays and added, subtracted, multiplied and
+// compared digit by digit, so they are exact at any length.
+package numeral
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+type Digit int
+
+// System is a positional number system. The i-th rune of the alphabet stands
+// for the digit minDigit+i.
+type System struct {
+	base     int
+	minDigit int
+	alphabet []rune
+	values   map[rune]Digit
+}
+
+const standardAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// SNAFU is the balanced base 5 of the fuel requirements.
+var SNAFU = MustSystem("=-012", -2)
+
+// NewSystem returns the system whose digits are the runes of the alphabet,
+// the first one valued minDigit. Zero and one must be among the digits.
+func NewSystem(alphabet string, minDigit int) (*System, error) {
+	s := &System{
+		alphabet: []rune(alphabet),
+		minDigit: minDigit,
+		values:   map[rune]Digit{},
+	}
+	s.base = len(s.alphabet)
+	if s.base < 2 {
+		return nil, errors.New("base must be at least 2")
+	}
+	if minDigit > 0 || minDigit+s.base-1 <= 0 {
+		// without a positive digit, not even 1 can be written
+		return nil, fmt.Errorf("digits %d to %d do not include zero and one", minDigit, minDigit+s.base-1)
+	}
+	for i, r := range s.alphabet {
+		if _, ok := s.values[r]; ok {
+			return nil, fmt.Errorf("digit %q used twice", r)
+		}
+		if r == '-' && minDigit == 0 {
+			return nil, errors.New("digit '-' clashes with the sign")
+		}
+		s.values[r] = Digit(minDigit + i)
+	}
+	return s, nil
+}
+
+func MustSystem(alphabet string, minDigit int) *System {
+	s, err := NewSystem(alphabet, minDigit)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Standard returns the base with digits 0 to base-1, written 0-9 then a-z.
+func Standard(base int) (*System, error) {
+	if base < 2 || base > len(standardAlphabet) {
+		return nil, fmt.Errorf("no standard alphabet for base %d", base)
+	}
+	return NewSystem(standardAlphabet[:base], 0)
+}
+
+// Balanced returns the base with digits centered on zero, -(base-1)/2 to
+// base/2, written with the alphabet.
+func Balanced(alphabet string) (*System, error) {
+	base := len([]rune(alphabet))
+	return NewSystem(alphabet, -(base-1)/2)
+}
+
+func (s *System) Base() int {
+	return s.base
+}
+
+func (s *System) maxDigit() int {
+	return s.minDigit + s.base - 1
+}
+
+// signed tells if the numbers need a sign, that is if the digits cannot
+// write negative numbers.
+func (s *System) signed() bool {
+	return s.minDigit == 0
+}
+
+// Number is an integer in a system. The zero value is not usable, numbers
+// come from the system.
+type Number struct {
+	sys *System
+	// digits, least significant first, without leading zeros
+	digits []Digit
+	// neg is only ever set in systems with no negative digits
+	neg bool
+}
+
+func (s *System) Zero() Number {
+	return Number{sys: s}
+}
+
+func (s *System) validDigit(d Digit) bool {
+	return int(d) >= s.minDigit && int(d) <= s.maxDigit()
+}
+
+// FromDigits returns the number with the digits, most significant first.
+func (s *System) FromDigits(digits []Digit) (Number, error) {
+	n := Number{sys: s, digits: make([]Digit, len(digits))}
+	for i, d := range digits {
+		if !s.validDigit(d) {
+			return Number{}, fmt.Errorf("digit %d out of range", d)
+		}
+		n.digits[len(digits)-1-i] = d
+	}
+	n.trim()
+	return n, nil
+}
+
+// Parse reads the number written with the alphabet, most significant digit
+// first. Standard systems take a leading '-' for negative numbers.
+func (s *System) Parse(str string) (Number, error) {
+	runes := []rune(str)
+	neg := false
+	if s.signed() && len(runes) > 0 && runes[0] == '-' {
+		neg = true
+		runes = runes[1:]
+	}
+	if len(runes) == 0 {
+		return Number{}, fmt.Errorf("no digits in %q", str)
+	}
+	digits := make([]Digit, len(runes))
+	for i, r := range runes {
+		d, ok := s.values[r]
+		if !ok {
+			return Number{}, fmt.Errorf("unknown digit %q in %q", r, str)
+		}
+		digits[i] = d
+	}
+	n, err := s.FromDigits(digits)
+	if err != nil {
+		return Number{}, err
+	}
+	n.neg = neg && !n.IsZero()
+	return n, nil
+}
+
+func (s *System) MustParse(str string) Number {
+	n, err := s.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// split returns q and d with n = q*base + d, d within [lo, lo+base-1].
+func (s *System) split(n int64, lo int) (int64, Digit) {
+	b := int64(s.base)
+	q, r := n/b, n%b
+	if r < int64(lo) {
+		r += b
+		q--
+	}
+	if r > int64(lo+s.base-1) {
+		r -= b
+		q++
+	}
+	return q, Digit(r)
+}
+
+func (s *System) FromInt(n int64) Number {
+	res := Number{sys: s}
+	lo := s.minDigit
+	if s.signed() && n < 0 {
+		// collect the negated digits, so that math.MinInt64 does not
+		// overflow
+		res.neg = true
+		lo = -(s.base - 1)
+	}
+	for n != 0 {
+		var d Digit
+		n, d = s.split(n, lo)
+		if res.neg {
+			d = -d
+		}
+		res.digits = append(res.digits, d)
+	}
+	return res
+}
+
+func (s *System) FromBig(n *big.Int) Number {
+	res := Number{sys: s}
+	lo := s.minDigit
+	if s.signed() && n.Sign() < 0 {
+		res.neg = true
+		lo = -(s.base - 1)
+	}
+	b := big.NewInt(int64(s.base))
+	q, r := new(big.Int).Set(n), new(big.Int)
+	for q.Sign() != 0 {
+		q.QuoRem(q, b, r)
+		d := r.Int64()
+		if d < int64(lo) {
+			d += int64(s.base)
+			q.Sub(q, big.NewInt(1))
+		}
+		if d > int64(lo+s.base-1) {
+			d -= int64(s.base)
+			q.Add(q, big.NewInt(1))
+		}
+		if res.neg {
+			d = -d
+		}
+		res.digits = append(res.digits, Digit(d))
+	}
+	return res
+}
+
+func (n Number) System() *System {
+	return n.sys
+}
+
+// Digits returns the digits, most significant first, a single zero digit
+// for zero. The sign of numbers in standard systems is not part of them.
+func (n Number) Digits() []Digit {
+	if n.IsZero() {
+		return []Digit{0}
+	}
+	res := make([]Digit, len(n.digits))
+	for i, d := range n.digits {
+		res[len(n.digits)-1-i] = d
+	}
+	return res
+}
+
+func (n Number) String() string {
+	runes := []rune{}
+	if n.neg {
+		runes = append(runes, '-')
+	}
+	for _, d := range n.Digits() {
+		runes = append(runes, n.sys.alphabet[int(d)-n.sys.minDigit])
+	}
+	return string(runes)
+}
+
+// Int64 returns the value of n, false if it does not fit.
+func (n Number) Int64() (int64, bool) {
+	b := int64(n.sys.base)
+	var res int64
+	for i := len(n.digits) - 1; i >= 0; i-- {
+		d := int64(n.digits[i])
+		if n.neg {
+			d = -d
+		}
+		if res > math.MaxInt64/b || res < math.MinInt64/b ||
+			d > 0 && res*b > math.MaxInt64-d || d < 0 && res*b < math.MinInt64-d {
+			// with negative digits the leading ones can go past the
+			// limits while the whole number does not
+			v := n.Big()
+			if !v.IsInt64() {
+				return 0, false
+			}
+			return v.Int64(), true
+		}
+		res = res*b + d
+	}
+	return res, true
+}
+
+func (n Number) Big() *big.Int {
+	b := big.NewInt(int64(n.sys.base))
+	res := new(big.Int)
+	for i := len(n.digits) - 1; i >= 0; i-- {
+		res.Mul(res, b)
+		res.Add(res, big.NewInt(int64(n.digits[i])))
+	}
+	if n.neg {
+		res.Neg(res)
+	}
+	return res
+}
+
+func (n Number) IsZero() bool {
+	return len(n.digits) == 0
+}
+
+// Sign returns -1, 0 or 1. The leading digit outweighs all the others, so it
+// gives the sign.
+func (n Number) Sign() int {
+	switch {
+	case n.IsZero():
+		return 0
+	case n.neg || n.digits[len(n.digits)-1] < 0:
+		return -1
+	}
+	return 1
+}
+
+func (n *Number) trim() {
+	i := len(n.digits)
+	for i > 0 && n.digits[i-1] == 0 {
+		i--
+	}
+	n.digits = n.digits[:i]
+	if i == 0 {
+		n.neg = false
+	}
+}
+
+func (n Number) check(o Number) {
+	if n.sys != o.sys {
+		panic("numbers from different systems")
+	}
+}
+
+// normalize turns column sums, least significant first, into digits. Any
+// sum is fine as long as the result can be written without a sign.
+func (s *System) normalize(sums []int64) []Digit {
+	res := make([]Digit, 0, len(sums)+2)
+	var carry int64
+	for i := 0; i < len(sums) || carry != 0; i++ {
+		v := carry
+		if i < len(sums) {
+			v += sums[i]
+		}
+		var d Digit
+		carry, d = s.split(v, s.minDigit)
+		res = append(res, d)
+	}
+	return res
+}
+
+// columns adds the digits of a and sign times the digits of b, column by
+// column.
+func columns(a, b []Digit, sign int64) []int64 {
+	sums := make([]int64, len(a))
+	for i, d := range a {
+		sums[i] = int64(d)
+	}
+	for i, d := range b {
+		if i == len(sums) {
+			sums = append(sums, 0)
+		}
+		sums[i] += sign * int64(d)
+	}
+	return sums
+}
+
+// cmpAbs compares the digits of two numbers of a standard system.
+func cmpAbs(a, b []Digit) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func (n Number) Neg() Number {
+	res := Number{sys: n.sys}
+	if n.sys.signed() {
+		res.digits = append([]Digit(nil), n.digits...)
+		res.neg = !n.neg
+	} else {
+		res.digits = n.sys.normalize(columns(nil, n.digits, -1))
+	}
+	res.trim()
+	return res
+}
+
+func (n Number) Add(o Number) Number {
+	n.check(o)
+	res := Number{sys: n.sys}
+	switch {
+	case !n.sys.signed():
+		res.digits = n.sys.normalize(columns(n.digits, o.digits, 1))
+	case n.neg == o.neg:
+		res.digits = n.sys.normalize(columns(n.digits, o.digits, 1))
+		res.neg = n.neg
+	case cmpAbs(n.digits, o.digits) >= 0:
+		// the bigger magnitude goes first, so that no sign is needed
+		res.digits = n.sys.normalize(columns(n.digits, o.digits, -1))
+		res.neg = n.neg
+	default:
+		res.digits = n.sys.normalize(columns(o.digits, n.digits, -1))
+		res.neg = o.neg
+	}
+	res.trim()
+	return res
+}
+
+func (n Number) Sub(o Number) Number {
+	return n.Add(o.Neg())
+}
+
+func (n Number) Mul(o Number) Number {
+	n.check(o)
+	res := Number{sys: n.sys}
+	if n.IsZero() || o.IsZero() {
+		return res
+	}
+	sums := make([]int64, len(n.digits)+len(o.digits))
+	for i, a := range n.digits {
+		for j, b := range o.digits {
+			sums[i+j] += int64(a) * int64(b)
+		}
+	}
+	res.digits = n.sys.normalize(sums)
+	res.neg = n.neg != o.neg
+	res.trim()
+	return res
+}
+
+// Cmp returns -1, 0 or 1 as n is less than, equal to or greater than o.
+func (n Number) Cmp(o Number) int {
+	return n.Sub(o).Sign()
+}
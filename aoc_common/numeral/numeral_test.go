@@ -0,0 +1,185 @@
+package numeral
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"kfet.org/aoc_common/assert"
+)
+
+var testSystems = []*System{
+	SNAFU,
+	MustSystem("0123456789", 0),
+	MustSystem("01", 0),
+	MustSystem("T01", -1),   // balanced ternary
+	MustSystem("-0123", -1), // base 4 with one negative digit
+}
+
+func TestSNAFU(t *testing.T) {
+	for _, tc := range []struct {
+		dec   int64
+		snafu string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{3, "1="},
+		{8, "2="},
+		{10, "20"},
+		{2022, "1=11-2"},
+		{12345, "1-0---0"},
+		{314159265, "1121-1110-1=0"},
+		{-1, "-"},
+		{-3, "-2"},
+		{4890, "2=-1=0"},
+	} {
+		n := SNAFU.FromInt(tc.dec)
+		assert.EqualsT(t, tc.snafu, n.String())
+
+		p, err := SNAFU.Parse(tc.snafu)
+		assert.NoErrT(t, err)
+		v, ok := p.Int64()
+		assert.EqualsT(t, true, ok)
+		assert.EqualsT(t, tc.dec, v)
+	}
+}
+
+func TestSystems(t *testing.T) {
+	_, err := NewSystem("0", 0)
+	assert.EqualsT(t, true, err != nil)
+	_, err = NewSystem("ab", -1)
+	assert.EqualsT(t, true, err != nil)
+	_, err = NewSystem("012", 1)
+	assert.EqualsT(t, true, err != nil)
+	_, err = NewSystem("0120", 0)
+	assert.EqualsT(t, true, err != nil)
+	_, err = NewSystem("-012", 0)
+	assert.EqualsT(t, true, err != nil)
+
+	hex, err := Standard(16)
+	assert.NoErrT(t, err)
+	assert.EqualsT(t, "-ff", hex.FromInt(-255).String())
+	assert.EqualsT(t, "0", hex.MustParse("-000").String())
+
+	bt, err := Balanced("-0+")
+	assert.NoErrT(t, err)
+	assert.EqualsT(t, "+--", bt.FromInt(5).String())
+	assert.EqualsT(t, []Digit{1, -1, -1}, bt.FromInt(5).Digits())
+
+	_, err = SNAFU.Parse("")
+	assert.EqualsT(t, true, err != nil)
+	_, err = SNAFU.Parse("12a")
+	assert.EqualsT(t, true, err != nil)
+	_, err = SNAFU.FromDigits([]Digit{3})
+	assert.EqualsT(t, true, err != nil)
+}
+
+func TestLimits(t *testing.T) {
+	for _, s := range testSystems {
+		for _, v := range []int64{math.MaxInt64, math.MinInt64, math.MaxInt64 - 1, math.MinInt64 + 1} {
+			n := s.FromInt(v)
+			got, ok := n.Int64()
+			assert.EqualsT(t, true, ok)
+			assert.EqualsT(t, v, got)
+			assert.EqualsT(t, 0, n.Big().Cmp(big.NewInt(v)))
+		}
+
+		// one past the limits no longer fits an int64
+		one := s.FromInt(1)
+		_, ok := s.FromInt(math.MaxInt64).Add(one).Int64()
+		assert.EqualsT(t, false, ok)
+		_, ok = s.FromInt(math.MinInt64).Sub(one).Int64()
+		assert.EqualsT(t, false, ok)
+	}
+}
+
+func TestBig(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890123456789", 10)
+	for _, s := range testSystems {
+		n := s.FromBig(huge)
+		assert.EqualsT(t, 0, n.Big().Cmp(huge))
+
+		sq := n.Mul(n)
+		assert.EqualsT(t, 0, sq.Big().Cmp(new(big.Int).Mul(huge, huge)))
+		assert.EqualsT(t, 1, sq.Cmp(n))
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	for _, v := range []int64{0, 1, -1, 2022, math.MaxInt64, math.MinInt64} {
+		f.Add(v)
+	}
+	f.Fuzz(func(t *testing.T, v int64) {
+		for _, s := range testSystems {
+			n := s.FromInt(v)
+			got, ok := n.Int64()
+			if !ok || got != v {
+				t.Fatalf("%v: %d became %v, %d", s.alphabet, v, n, got)
+			}
+
+			p, err := s.Parse(n.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Cmp(n) != 0 || p.String() != n.String() {
+				t.Fatalf("%v: %v parsed as %v", s.alphabet, n, p)
+			}
+
+			d, err := s.FromDigits(n.Digits())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.signed() && v < 0 {
+				d = d.Neg()
+			}
+			if d.Cmp(n) != 0 {
+				t.Fatalf("%v: %v from digits is %v", s.alphabet, n, d)
+			}
+
+			if b := s.FromBig(big.NewInt(v)); b.String() != n.String() {
+				t.Fatalf("%v: %d from big is %v, not %v", s.alphabet, v, b, n)
+			}
+		}
+	})
+}
+
+func FuzzArithmetic(f *testing.F) {
+	f.Add(int64(0), int64(0))
+	f.Add(int64(1), int64(-1))
+	f.Add(int64(4890), int64(-2022))
+	f.Add(int64(math.MaxInt64), int64(math.MinInt64))
+	f.Fuzz(func(t *testing.T, a, b int64) {
+		ba, bb := big.NewInt(a), big.NewInt(b)
+		for _, s := range testSystems {
+			na, nb := s.FromInt(a), s.FromInt(b)
+			check := func(op string, got Number, want *big.Int) {
+				if got.Big().Cmp(want) != 0 {
+					t.Fatalf("%v: %d %s %d = %v, want %v", s.alphabet, a, op, b, got.Big(), want)
+				}
+			}
+			check("+", na.Add(nb), new(big.Int).Add(ba, bb))
+			check("-", na.Sub(nb), new(big.Int).Sub(ba, bb))
+			check("*", na.Mul(nb), new(big.Int).Mul(ba, bb))
+			if got, want := na.Cmp(nb), ba.Cmp(bb); got != want {
+				t.Fatalf("%v: cmp %d %d = %d, want %d", s.alphabet, a, b, got, want)
+			}
+		}
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range []string{"0", "1=11-2", "2=-1=0", "--", "=", "12a", ""} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, str string) {
+		n, err := SNAFU.Parse(str)
+		if err != nil {
+			return
+		}
+		// writing it back only drops the leading zeros
+		p, err := SNAFU.Parse(n.String())
+		if err != nil || p.Cmp(n) != 0 {
+			t.Fatalf("%q read as %v, read back as %v", str, n, p)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kfet.org/aoc_common/input"
+)
+
+type outcome int
+
+const (
+	lose outcome = iota
+	draw
+	win
+)
+
+func (o outcome) String() string {
+	return [...]string{"lose", "draw", "win"}[o]
+}
+
+// game is a hand game where each player shows a shape, and the win matrix
+// tells which shape beats which. Shapes not beating each other draw.
+type game struct {
+	name   string
+	shapes []shape
+	index  map[shape]int
+	// beats[i][j] tells if the i-th shape beats the j-th one
+	beats [][]bool
+}
+
+// NewGame returns the game of the shapes with the win matrix.
+func NewGame(name string, shapes []shape, beats [][]bool) (*game, error) {
+	if len(shapes) < 2 {
+		return nil, errors.New("a game needs at least two shapes")
+	}
+	if len(beats) != len(shapes) {
+		return nil, fmt.Errorf("win matrix has %d rows for %d shapes", len(beats), len(shapes))
+	}
+	g := &game{
+		name:   name,
+		shapes: shapes,
+		index:  map[shape]int{},
+		beats:  beats,
+	}
+	for i, s := range shapes {
+		if _, ok := g.index[s]; ok {
+			return nil, errors.New("shape used twice " + string(s))
+		}
+		g.index[s] = i
+		if len(beats[i]) != len(shapes) {
+			return nil, fmt.Errorf("win matrix row %s has %d columns for %d shapes", s, len(beats[i]), len(shapes))
+		}
+	}
+	for i := range shapes {
+		if beats[i][i] {
+			return nil, errors.New("shape beats itself " + string(shapes[i]))
+		}
+		for j := range shapes {
+			if beats[i][j] && beats[j][i] {
+				return nil, fmt.Errorf("shapes %s and %s beat each other", shapes[i], shapes[j])
+			}
+		}
+	}
+	return g, nil
+}
+
+// NewCyclicGame returns the game where the i-th shape beats the ones an odd
+// number of places before it, wrapping around. With an odd number of shapes,
+// each one beats half of the others: rock, paper, scissors, or rock, paper,
+// scissors, Spock, lizard.
+func NewCyclicGame(name string, shapes []shape) (*game, error) {
+	n := len(shapes)
+	if n%2 == 0 {
+		return nil, fmt.Errorf("cyclic game needs an odd number of shapes, not %d", n)
+	}
+	beats := make([][]bool, n)
+	for i := range beats {
+		beats[i] = make([]bool, n)
+		for j := range beats[i] {
+			beats[i][j] = ((i-j)%n+n)%n%2 == 1
+		}
+	}
+	return NewGame(name, shapes, beats)
+}
+
+func (g *game) shape(i int) (shape, error) {
+	if i < 0 || i >= len(g.shapes) {
+		return "", fmt.Errorf("no shape %d in %s", i, g.name)
+	}
+	return g.shapes[i], nil
+}
+
+func (g *game) play(them, me shape) (outcome, error) {
+	ti, ok := g.index[them]
+	if !ok {
+		return 0, errors.New("Unknown shape " + string(them))
+	}
+	mi, ok := g.index[me]
+	if !ok {
+		return 0, errors.New("Unknown shape " + string(me))
+	}
+	switch {
+	case g.beats[mi][ti]:
+		return win, nil
+	case g.beats[ti][mi]:
+		return lose, nil
+	}
+	return draw, nil
+}
+
+// shapeScore is one for the first shape, two for the second, and so on.
+func (g *game) shapeScore(s shape) (int, error) {
+	i, ok := g.index[s]
+	if !ok {
+		return 0, errors.New("Unknown shape score " + string(s))
+	}
+	return i + 1, nil
+}
+
+func (g *game) roundScore(them, me shape) (int, outcome, error) {
+	ss, err := g.shapeScore(me)
+	if err != nil {
+		return 0, 0, err
+	}
+	o, err := g.play(them, me)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ss + 3*int(o), o, nil
+}
+
+// shapeFor returns the first shape with the outcome against their shape.
+func (g *game) shapeFor(their shape, o outcome) (shape, error) {
+	for _, s := range g.shapes {
+		if got, err := g.play(their, s); err == nil && got == o {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("no shape to %s against %s", o, their)
+}
+
+// readGame reads a game from its win matrix: the shapes on the first line,
+// then a line per shape with its name and a W, L or D against each shape.
+// '#' starts a comment.
+//
+//	Rock Paper Scissors
+//	Rock     D L W
+//	Paper    W D L
+//	Scissors L W D
+func readGame(name string, r io.Reader) (*game, error) {
+	var shapes []shape
+	var cells [][]string
+	var lineNum int
+	err := input.ReadLines(r, func(line string) error {
+		lineNum++
+		if i := strings.IndexRune(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return nil
+		}
+		if shapes == nil {
+			for _, f := range fields {
+				shapes = append(shapes, shape(f))
+			}
+			return nil
+		}
+
+		if len(cells) == len(shapes) {
+			return fmt.Errorf("line %d: more rows than shapes", lineNum)
+		}
+		want := shapes[len(cells)]
+		if shape(fields[0]) != want || len(fields) != len(shapes)+1 {
+			return fmt.Errorf("line %d: expected the row of %s", lineNum, want)
+		}
+		for _, f := range fields[1:] {
+			if f != "W" && f != "L" && f != "D" {
+				return fmt.Errorf("line %d: unknown outcome %s", lineNum, f)
+			}
+		}
+		cells = append(cells, fields[1:])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(cells) != len(shapes) {
+		return nil, fmt.Errorf("%d rows for %d shapes", len(cells), len(shapes))
+	}
+
+	// losses and draws are implied by the wins, but must agree with them
+	opposite := map[string]string{"W": "L", "L": "W", "D": "D"}
+	beats := make([][]bool, len(shapes))
+	for i := range cells {
+		beats[i] = make([]bool, len(shapes))
+		for j := range cells[i] {
+			if cells[j][i] != opposite[cells[i][j]] {
+				return nil, fmt.Errorf("%s against %s is %s, but %s against %s is %s",
+					shapes[i], shapes[j], cells[i][j], shapes[j], shapes[i], cells[j][i])
+			}
+			beats[i][j] = cells[i][j] == "W"
+		}
+	}
+	return NewGame(name, shapes, beats)
+}
+
+func readGameFile(fileName string) (*game, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	name := filepath.Base(fileName)
+	return readGame(strings.TrimSuffix(name, filepath.Ext(name)), file)
+}
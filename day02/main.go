@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"kfet.org/aoc_common/assert"
@@ -12,111 +13,100 @@ import (
 type shape string
 
 const (
-	rock     = "Rock"
-	paper    = "Paper"
-	scissors = "Scissors"
+	rock     shape = "Rock"
+	paper    shape = "Paper"
+	scissors shape = "Scissors"
 )
 
-var theirCode = map[string]shape{
-	"A": rock,
-	"B": paper,
-	"C": scissors,
-}
-
-var myCode = map[string]shape{
-	"X": rock,
-	"Y": paper,
-	"Z": scissors,
+func classicGame() *game {
+	g, err := NewCyclicGame("rock paper scissors", []shape{rock, paper, scissors})
+	if err != nil {
+		panic(err)
+	}
+	return g
 }
 
-type round struct {
-	them shape
-	me   shape
-}
+// decoder turns the code of a strategy line into my shape, knowing theirs.
+type decoder func(g *game, their shape, code string) (shape, error)
 
-var (
-	shapeScoreMap = map[shape]int{
-		rock:     1,
-		paper:    2,
-		scissors: 3,
+func codeIndex(letters string, code string) (int, error) {
+	i := strings.Index(letters, code)
+	if len(code) != 1 || i < 0 {
+		return 0, errors.New("Unknown code " + code)
 	}
+	return i, nil
+}
 
-	roundScoreMap = map[round]int{
-		{rock, rock}:         3,
-		{rock, paper}:        6,
-		{rock, scissors}:     0,
-		{paper, rock}:        0,
-		{paper, paper}:       3,
-		{paper, scissors}:    6,
-		{scissors, rock}:     6,
-		{scissors, paper}:    0,
-		{scissors, scissors}: 3,
+// byShape reads the i-th letter as the i-th shape of the game.
+func byShape(letters string) decoder {
+	return func(g *game, their shape, code string) (shape, error) {
+		i, err := codeIndex(letters, code)
+		if err != nil {
+			return "", err
+		}
+		return g.shape(i)
 	}
-)
+}
 
-func roundScore(them, me shape) (int, error) {
-	ss, ok := shapeScoreMap[shape(me)]
-	if !ok {
-		return 0, errors.New("Unknown shape score " + string(me))
-	}
-	rs, ok := roundScoreMap[round{them, me}]
-	if !ok {
-		return 0, errors.New("Unknown shapes " + string(them) + ", " + string(me))
+// byOutcome reads the letters as lose, draw and win, and picks the shape
+// with that outcome.
+func byOutcome(letters string) decoder {
+	return func(g *game, their shape, code string) (shape, error) {
+		i, err := codeIndex(letters, code)
+		if err != nil {
+			return "", err
+		}
+		return g.shapeFor(their, outcome(i))
 	}
-
-	return ss + rs, nil
 }
 
-func myCodeQuizOne(their shape, code string) shape {
-	return myCode[code]
-}
+const theirLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
-func myCodeQuizTwo(their shape, mine string) shape {
-	type codeRound struct {
-		their shape
-		my    string
+var (
+	theirCode      = byShape(theirLetters)
+	myCodeQuizOne  = byShape("XYZ")
+	myCodeQuizTwo  = byOutcome("XYZ")
+	quizStrategies = []strategy{
+		{"shape", myCodeQuizOne},
+		{"outcome", myCodeQuizTwo},
 	}
+)
 
-	m := map[codeRound]shape{
-		{rock, "X"}:     scissors,
-		{rock, "Y"}:     rock,
-		{rock, "Z"}:     paper,
-		{paper, "X"}:    rock,
-		{paper, "Y"}:    paper,
-		{paper, "Z"}:    scissors,
-		{scissors, "X"}: paper,
-		{scissors, "Y"}: scissors,
-		{scissors, "Z"}: rock,
-	}
-	return m[codeRound{their, mine}]
+// codedRound is a line of the strategy guide.
+type codedRound struct {
+	their, mine string
 }
 
-func strategyScore(fileName string, codeFn func(shape, string) shape) (int, error) {
-	var score int
+func readRounds(fileName string) ([]codedRound, error) {
+	res := []codedRound{}
 	err := input.ReadFileLinesStrings(fileName, func(tokens []string) error {
 		if len(tokens) != 2 {
-			return errors.New("Wront number of argumetns " + strings.Join(tokens, " "))
-		}
-		theirShape := theirCode[tokens[0]]
-		myShape := codeFn(theirShape, tokens[1])
-		rs, err := roundScore(theirShape, myShape)
-
-		if err != nil {
-			return err
+			return errors.New("Wrong number of arguments " + strings.Join(tokens, " "))
 		}
-
-		score += rs
+		res = append(res, codedRound{tokens[0], tokens[1]})
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
 
+func strategyScore(fileName string, g *game, codeFn decoder) (int, error) {
+	rounds, err := readRounds(fileName)
 	if err != nil {
 		return 0, err
 	}
-	return score, nil
+	res, err := play(g, rounds, strategy{"", codeFn})
+	if err != nil {
+		return 0, err
+	}
+	return res.total(), nil
 }
 
 func main() {
-	score, err := strategyScore("data/part_one_small.txt", myCodeQuizOne)
+	g := classicGame()
+	score, err := strategyScore("data/part_one_small.txt", g, myCodeQuizOne)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -125,7 +115,7 @@ func main() {
 	fmt.Println("=============")
 	assert.Equals(15, score, "")
 
-	score, err = strategyScore("data/input.txt", myCodeQuizOne)
+	score, err = strategyScore("data/input.txt", g, myCodeQuizOne)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -134,7 +124,7 @@ func main() {
 	fmt.Println("=============")
 	assert.Equals(12458, score, "")
 
-	score, err = strategyScore("data/part_one_small.txt", myCodeQuizTwo)
+	score, err = strategyScore("data/part_one_small.txt", g, myCodeQuizTwo)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -143,7 +133,7 @@ func main() {
 	fmt.Println("=============")
 	assert.Equals(12, score, "")
 
-	score, err = strategyScore("data/input.txt", myCodeQuizTwo)
+	score, err = strategyScore("data/input.txt", g, myCodeQuizTwo)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -151,4 +141,25 @@ func main() {
 	fmt.Println(score)
 	fmt.Println("=============")
 	assert.Equals(12683, score, "")
+
+	err = tournamentFile("data/part_one_small.txt", g, quizStrategies, os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("=============")
+
+	rpsls, err := readGameFile("data/rpsls.txt")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = tournamentFile("data/rpsls_small.txt", rpsls, []strategy{
+		{"shape", byShape("VWXYZ")},
+		{"outcome", byOutcome("XYZ")},
+	}, os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrategyScore(t *testing.T) {
+	g := classicGame()
+	for _, tc := range []struct {
+		fileName string
+		codeFn   decoder
+		want     int
+	}{
+		{"data/part_one_small.txt", myCodeQuizOne, 15},
+		{"data/input.txt", myCodeQuizOne, 12458},
+		{"data/part_one_small.txt", myCodeQuizTwo, 12},
+		{"data/input.txt", myCodeQuizTwo, 12683},
+	} {
+		score, err := strategyScore(tc.fileName, g, tc.codeFn)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, score)
+	}
+}
+
+func TestCyclicGame(t *testing.T) {
+	rpsls, err := NewCyclicGame("rpsls", []shape{rock, paper, scissors, "Spock", "Lizard"})
+	assert.Nil(t, err)
+	fromFile, err := readGameFile("data/rpsls.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "rpsls", fromFile.name)
+	assert.Equal(t, rpsls.shapes, fromFile.shapes)
+	assert.Equal(t, rpsls.beats, fromFile.beats)
+
+	for _, tc := range []struct {
+		them, me shape
+		want     outcome
+	}{
+		{rock, paper, win},
+		{paper, "Lizard", win},
+		{"Spock", "Lizard", win},
+		{"Lizard", rock, win},
+		{scissors, "Spock", win},
+		{"Spock", scissors, lose},
+		{"Lizard", "Lizard", draw},
+	} {
+		o, err := rpsls.play(tc.them, tc.me)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, o, "%s against %s", tc.me, tc.them)
+	}
+
+	// every shape beats half of the others
+	for i := range rpsls.shapes {
+		wins := 0
+		for j := range rpsls.shapes {
+			if rpsls.beats[i][j] {
+				wins++
+			}
+		}
+		assert.Equal(t, 2, wins)
+	}
+
+	_, err = NewCyclicGame("even", []shape{rock, paper})
+	assert.NotNil(t, err)
+	_, err = rpsls.play(rock, "Well")
+	assert.NotNil(t, err)
+}
+
+func TestReadGame(t *testing.T) {
+	g, err := readGame("coin", strings.NewReader("Heads Tails\nHeads D W\nTails L D\n"))
+	assert.Nil(t, err)
+	s, err := g.shapeFor("Heads", lose)
+	assert.Nil(t, err)
+	assert.Equal(t, shape("Tails"), s)
+	_, err = g.shapeFor("Heads", win)
+	assert.NotNil(t, err)
+
+	for _, text := range []string{
+		"Heads Tails\nHeads D W\nTails D D\n",
+		"Heads Tails\nHeads D W\n",
+		"Heads Tails\nTails D W\nHeads L D\n",
+		"Heads Tails\nHeads D X\nTails L D\n",
+		"Heads Tails\nHeads W L\nTails W D\n",
+	} {
+		_, err := readGame("bad", strings.NewReader(text))
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestTournament(t *testing.T) {
+	g := classicGame()
+	rounds, err := readRounds("data/part_one_small.txt")
+	assert.Nil(t, err)
+	results, err := tournament(g, rounds, quizStrategies)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, 15, results[0].total())
+	assert.Equal(t, 12, results[1].total())
+	assert.Equal(t, roundResult{them: scissors, me: rock, outcome: win, score: 7, total: 12}, results[1].rounds[2])
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeReport(&buf, g, results))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 7, len(lines))
+	assert.Equal(t, "1     Rock     | Paper    win     8     8     | Rock    draw    4     4", strings.TrimSpace(lines[3]))
+	assert.Equal(t, []string{"total", "|", "15", "|", "12"}, strings.Fields(lines[6]))
+
+	_, err = tournament(g, []codedRound{{"A", "Q"}}, quizStrategies)
+	assert.NotNil(t, err)
+}
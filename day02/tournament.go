@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// strategy is a named way of reading my column of the strategy guide.
+type strategy struct {
+	name   string
+	decode decoder
+}
+
+type roundResult struct {
+	them, me shape
+	outcome  outcome
+	score    int
+	// total is the score of the rounds so far, this one included
+	total int
+}
+
+type strategyResult struct {
+	name   string
+	rounds []roundResult
+}
+
+func (sr *strategyResult) total() int {
+	if len(sr.rounds) == 0 {
+		return 0
+	}
+	return sr.rounds[len(sr.rounds)-1].total
+}
+
+// play plays the rounds following the strategy.
+func play(g *game, rounds []codedRound, s strategy) (*strategyResult, error) {
+	res := &strategyResult{name: s.name}
+	var total int
+	for i, r := range rounds {
+		them, err := theirCode(g, "", r.their)
+		if err != nil {
+			return nil, fmt.Errorf("round %d: %w", i+1, err)
+		}
+		me, err := s.decode(g, them, r.mine)
+		if err != nil {
+			return nil, fmt.Errorf("round %d: %w", i+1, err)
+		}
+		score, o, err := g.roundScore(them, me)
+		if err != nil {
+			return nil, fmt.Errorf("round %d: %w", i+1, err)
+		}
+		total += score
+		res.rounds = append(res.rounds, roundResult{
+			them:    them,
+			me:      me,
+			outcome: o,
+			score:   score,
+			total:   total,
+		})
+	}
+	return res, nil
+}
+
+// tournament plays the same rounds with each strategy.
+func tournament(g *game, rounds []codedRound, strategies []strategy) ([]*strategyResult, error) {
+	res := make([]*strategyResult, 0, len(strategies))
+	for _, s := range strategies {
+		sr, err := play(g, rounds, s)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", s.name, err)
+		}
+		res = append(res, sr)
+	}
+	return res, nil
+}
+
+// writeReport writes a line per round with their shape, then my shape, the
+// outcome, the round score and the cumulative score for each strategy,
+// followed by the totals.
+func writeReport(w io.Writer, g *game, results []*strategyResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(tw, "== %s ==\n", g.name)
+	fmt.Fprint(tw, "\t\t")
+	for _, sr := range results {
+		fmt.Fprintf(tw, "| %s\t\t\t\t", sr.name)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprint(tw, "round\tthem\t")
+	for range results {
+		fmt.Fprint(tw, "| me\toutcome\tscore\ttotal\t")
+	}
+	fmt.Fprintln(tw)
+
+	if len(results) > 0 {
+		for i, r := range results[0].rounds {
+			fmt.Fprintf(tw, "%d\t%s\t", i+1, r.them)
+			for _, sr := range results {
+				r := sr.rounds[i]
+				fmt.Fprintf(tw, "| %s\t%s\t%d\t%d\t", r.me, r.outcome, r.score, r.total)
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+
+	fmt.Fprint(tw, "total\t\t")
+	for _, sr := range results {
+		fmt.Fprintf(tw, "|\t\t\t%d\t", sr.total())
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
+
+func tournamentFile(fileName string, g *game, strategies []strategy, w io.Writer) error {
+	rounds, err := readRounds(fileName)
+	if err != nil {
+		return err
+	}
+	results, err := tournament(g, rounds, strategies)
+	if err != nil {
+		return err
+	}
+	return writeReport(w, g, results)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParts(t *testing.T) {
+	res, err := partOne("data/part_one_short.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 157, res)
+	res, err = partOne("data/input.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 7903, res)
+
+	res, err = partTwo("data/part_one_short.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 70, res)
+	res, err = partTwo("data/input.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 2548, res)
+}
+
+func TestItemSet(t *testing.T) {
+	s, err := newItemSet("zaZAab")
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 26, 27, 52}, s.priorities())
+	assert.Equal(t, "abzAZ", s.String())
+	assert.Equal(t, 108, s.priority())
+
+	o, _ := newItemSet("bZq")
+	assert.Equal(t, "bZ", intersect(s, o).String())
+	assert.Equal(t, s, intersect(s))
+	assert.Equal(t, itemSet(0), intersect())
+	assert.Equal(t, 52, len(allItems.priorities()))
+
+	_, err = newItemSet("ab1")
+	assert.NotNil(t, err)
+}
+
+func TestGroupings(t *testing.T) {
+	rucksacks := "abcXabcY\nabcZabcW\nxbcaxbca\nqbrsqbrs\n"
+	for _, tc := range []struct {
+		g    grouping
+		want []string
+	}{
+		{grouping{1, 2}, []string{"abc", "abc", "abcx", "bqrs"}},
+		{grouping{1, 4}, []string{"", "", "", ""}},
+		{grouping{2, 1}, []string{"abc", "b"}},
+		{grouping{4, 1}, []string{"b"}},
+		{grouping{2, 2}, []string{"abc", "b"}},
+	} {
+		results, err := analyze(strings.NewReader(rucksacks), tc.g)
+		assert.Nil(t, err)
+		got := []string{}
+		for _, r := range results {
+			got = append(got, r.shared.String())
+		}
+		assert.Equal(t, tc.want, got, "%v", tc.g)
+	}
+
+	_, err := analyze(strings.NewReader(rucksacks), grouping{3, 1})
+	assert.NotNil(t, err)
+	_, err = analyze(strings.NewReader(rucksacks), grouping{1, 3})
+	assert.NotNil(t, err)
+}
+
+func TestReport(t *testing.T) {
+	results, err := analyzeFile("data/part_one_short.txt", partOneGrouping)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	assert.Nil(t, writeReport(&buf, results, partOneGrouping))
+	assert.Equal(t, `group 1, lines 1-1: p 16
+group 2, lines 2-2: L 38
+group 3, lines 3-3: P 42
+group 4, lines 4-4: v 22
+group 5, lines 5-5: t 20
+group 6, lines 6-6: s 19
+sum 157
+`, buf.String())
+}
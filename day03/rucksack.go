@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"kfet.org/aoc_common/input"
+)
+
+// itemSet is a set of item types, bit p set for the item of priority p.
+type itemSet uint64
+
+// allItems holds every item type, the neutral set of intersections.
+const allItems itemSet = (1<<53 - 1) &^ 1
+
+func itemRune(priority int) rune {
+	if priority <= 26 {
+		return 'a' + rune(priority-1)
+	}
+	return 'A' + rune(priority-27)
+}
+
+func newItemSet(items string) (itemSet, error) {
+	var res itemSet
+	for _, r := range items {
+		p := runePriority(r)
+		if p == 0 {
+			return 0, fmt.Errorf("unknown item %q", r)
+		}
+		res |= 1 << p
+	}
+	return res, nil
+}
+
+// intersect returns the items found in all the sets, none without sets.
+func intersect(sets ...itemSet) itemSet {
+	if len(sets) == 0 {
+		return 0
+	}
+	res := allItems
+	for _, s := range sets {
+		res &= s
+	}
+	return res
+}
+
+// priorities returns the priorities of the items, in increasing order.
+func (s itemSet) priorities() []int {
+	res := []int{}
+	for p := 1; p <= 52; p++ {
+		if s&(1<<p) != 0 {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func (s itemSet) priority() int {
+	var sum int
+	for _, p := range s.priorities() {
+		sum += p
+	}
+	return sum
+}
+
+func (s itemSet) String() string {
+	var sb strings.Builder
+	for _, p := range s.priorities() {
+		sb.WriteRune(itemRune(p))
+	}
+	return sb.String()
+}
+
+// compartments splits the rucksack into n compartments of the same size.
+func compartments(line string, n int) ([]itemSet, error) {
+	if n < 1 || len(line)%n != 0 {
+		return nil, fmt.Errorf("cannot split %s in %d compartments", line, n)
+	}
+	size := len(line) / n
+	res := make([]itemSet, n)
+	for i := range res {
+		s, err := newItemSet(line[i*size : (i+1)*size])
+		if err != nil {
+			return nil, err
+		}
+		res[i] = s
+	}
+	return res, nil
+}
+
+// grouping tells how to look for shared items: the rucksacks go in groups
+// of groupSize lines, each split into compartments, and the items shared
+// by all the compartments of all the rucksacks of a group are its shared
+// items. The first part is groups of one with two compartments, the second
+// groups of three with one.
+type grouping struct {
+	groupSize    int
+	compartments int
+}
+
+var (
+	partOneGrouping = grouping{groupSize: 1, compartments: 2}
+	partTwoGrouping = grouping{groupSize: 3, compartments: 1}
+)
+
+type groupResult struct {
+	// first line of the group, counting from one
+	line   int
+	shared itemSet
+}
+
+func analyze(r io.Reader, g grouping) ([]groupResult, error) {
+	if g.groupSize < 1 {
+		return nil, errors.New("groups need at least one rucksack")
+	}
+	res := []groupResult{}
+	var sets []itemSet
+	var lineNum int
+	err := input.ReadLines(r, func(line string) error {
+		lineNum++
+		cs, err := compartments(line, g.compartments)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		sets = append(sets, cs...)
+		if lineNum%g.groupSize == 0 {
+			res = append(res, groupResult{
+				line:   lineNum - g.groupSize + 1,
+				shared: intersect(sets...),
+			})
+			sets = sets[:0]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if lineNum%g.groupSize != 0 {
+		return nil, fmt.Errorf("%d rucksacks do not make groups of %d", lineNum, g.groupSize)
+	}
+	return res, nil
+}
+
+func analyzeFile(fileName string, g grouping) ([]groupResult, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return analyze(file, g)
+}
+
+func sumPriorities(results []groupResult) int {
+	var sum int
+	for _, r := range results {
+		sum += r.shared.priority()
+	}
+	return sum
+}
+
+// writeReport writes a line per group with its lines, shared items and their
+// priority, then the sum of the priorities.
+func writeReport(w io.Writer, results []groupResult, g grouping) error {
+	bw := bufio.NewWriter(w)
+	for i, r := range results {
+		shared := r.shared.String()
+		if shared == "" {
+			shared = "-"
+		}
+		fmt.Fprintf(bw, "group %d, lines %d-%d: %s %d\n", i+1, r.line, r.line+g.groupSize-1, shared, r.shared.priority())
+	}
+	fmt.Fprintf(bw, "sum %d\n", sumPriorities(results))
+	return bw.Flush()
+}
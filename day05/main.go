@@ -7,8 +7,10 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"kfet.org/aoc_common/assert"
+	"kfet.org/aoc_common/calc"
 	"kfet.org/aoc_common/input"
 )
 
@@ -40,33 +42,141 @@ func (s stacks) String() string {
 	return sb.String()
 }
 
-func moveOne(s *stacks, count, from, to int) {
-	// move the crates one by one
-	for i := 0; i < count; i++ {
-		// pop from 'from'
-		c := (*s)[from][0]
-		(*s)[from] = (*s)[from][1:]
-		// prepend to 'to'
-		(*s)[to] = append([]*crate{NewCrate(rune(*c))}, (*s)[to]...)
+// CrateMover moves count crates from the top of one stack to the top of
+// another.
+type CrateMover interface {
+	move(s stacks, count, from, to int) error
+}
+
+// crane lifts at most maxLift crates at a time, keeping their order within a
+// single lift. maxLift of 0 means no limit.
+type crane struct {
+	maxLift int
+}
+
+// NewCrateMover9000 moves the crates one by one.
+func NewCrateMover9000() CrateMover {
+	return &crane{maxLift: 1}
+}
+
+// NewCrateMover9001 moves all the crates at once.
+func NewCrateMover9001() CrateMover {
+	return &crane{}
+}
+
+func NewCrane(maxLift int) CrateMover {
+	return &crane{maxLift: maxLift}
+}
+
+// checkMove tells why the move cannot be made on the stacks, if it cannot.
+func checkMove(s stacks, count, from, to int) error {
+	if from < 0 || from >= len(s) || to < 0 || to >= len(s) {
+		return fmt.Errorf("no such stack, from %d to %d", from+1, to+1)
+	}
+	if count < 1 {
+		return fmt.Errorf("cannot move %d crates", count)
+	}
+	if count > len(s[from]) {
+		return fmt.Errorf("cannot move %d crates, stack %d has %d", count, from+1, len(s[from]))
+	}
+	return nil
+}
+
+func (c *crane) move(s stacks, count, from, to int) error {
+	if err := checkMove(s, count, from, to); err != nil {
+		return err
+	}
+
+	for count > 0 {
+		lift := count
+		if c.maxLift > 0 && lift > c.maxLift {
+			lift = c.maxLift
+		}
+		// pop 'lift' crates
+		l := s[from][0:lift]
+		fs := make(stack, 0)
+		fs = append(fs, s[from][lift:]...)
+		s[from] = fs
+
+		// prepend them to 'to'
+		fs = make(stack, 0)
+		fs = append(fs, l...)
+		fs = append(fs, s[to]...)
+		s[to] = fs
+
+		count -= lift
+	}
+	return nil
+}
+
+type moveRecord struct {
+	count, from, to int
+	// the crates as they were on 'from' before the move, top first
+	taken stack
+}
+
+// crateYard applies moves with a crane and keeps a log of them for undo and
+// redo.
+type crateYard struct {
+	s      stacks
+	mover  CrateMover
+	done   []*moveRecord
+	undone []*moveRecord
+}
+
+func NewCrateYard(s stacks, mover CrateMover) *crateYard {
+	return &crateYard{
+		s:     s,
+		mover: mover,
+	}
+}
+
+func (y *crateYard) apply(count, from, to int) error {
+	err := y.exec(&moveRecord{count: count, from: from, to: to})
+	if err != nil {
+		return err
+	}
+	y.undone = y.undone[:0]
+	return nil
+}
+
+func (y *crateYard) exec(m *moveRecord) error {
+	if err := checkMove(y.s, m.count, m.from, m.to); err != nil {
+		return err
 	}
+	m.taken = append(make(stack, 0), y.s[m.from][0:m.count]...)
+	if err := y.mover.move(y.s, m.count, m.from, m.to); err != nil {
+		return err
+	}
+	y.done = append(y.done, m)
+	return nil
 }
 
-func moveTwo(s *stacks, count, from, to int) {
-	// move the crates all at once
-	// pop 'count' crates
-	c := (*s)[from][0:count]
-	fs := make(stack, 0)
-	fs = append(fs, (*s)[from][count:]...)
-	(*s)[from] = fs
+func (y *crateYard) undo() error {
+	if len(y.done) == 0 {
+		return errors.New("nothing to undo")
+	}
+	m := y.done[len(y.done)-1]
+	y.done = y.done[:len(y.done)-1]
+
+	// take the moved crates off 'to' and put them back on 'from' as they were
+	y.s[m.to] = append(make(stack, 0), y.s[m.to][m.count:]...)
+	y.s[m.from] = append(append(make(stack, 0), m.taken...), y.s[m.from]...)
 
-	// prepend them to 'to'
-	fs = make(stack, 0)
-	fs = append(fs, c...)
-	fs = append(fs, (*s)[to]...)
-	(*s)[to] = fs
+	y.undone = append(y.undone, m)
+	return nil
 }
 
-func (s *stacks) moveCrates(scan *bufio.Scanner, moveFunc func(*stacks, int, int, int)) error {
+func (y *crateYard) redo() error {
+	if len(y.undone) == 0 {
+		return errors.New("nothing to redo")
+	}
+	m := y.undone[len(y.undone)-1]
+	y.undone = y.undone[:len(y.undone)-1]
+	return y.exec(m)
+}
+
+func (y *crateYard) moveCrates(scan *bufio.Scanner) error {
 
 	for scan.Scan() {
 		line := scan.Text()
@@ -75,7 +185,9 @@ func (s *stacks) moveCrates(scan *bufio.Scanner, moveFunc func(*stacks, int, int
 			return errors.New("wrong move line format " + line)
 		}
 		nums := input.MustAtoInts(tokens[1:])
-		moveFunc(s, nums[0], nums[1]-1, nums[2]-1)
+		if err := y.apply(nums[0], nums[1]-1, nums[2]-1); err != nil {
+			return err
+		}
 	}
 	if err := scan.Err(); err != nil {
 		return err
@@ -84,13 +196,62 @@ func (s *stacks) moveCrates(scan *bufio.Scanner, moveFunc func(*stacks, int, int
 	return nil
 }
 
+// drawing renders the stacks in the same format readStacks reads, including
+// the stack numbers line.
+func (s stacks) drawing() string {
+	height := 0
+	for _, st := range s {
+		height = calc.Max(height, len(st))
+	}
+
+	var sb strings.Builder
+	for level := height; level > 0; level-- {
+		for i, st := range s {
+			if i > 0 {
+				sb.WriteRune(' ')
+			}
+			if len(st) < level {
+				sb.WriteString("   ")
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("[%c]", rune(*st[len(st)-level])))
+		}
+		sb.WriteRune('\n')
+	}
+	for i := range s {
+		if i > 0 {
+			sb.WriteRune(' ')
+		}
+		sb.WriteString(fmt.Sprintf(" %d ", i+1))
+	}
+	sb.WriteRune('\n')
+	return sb.String()
+}
+
+func (s stacks) tops() string {
+	var sb strings.Builder
+	for _, st := range s {
+		if len(st) == 0 {
+			sb.WriteRune(' ')
+			continue
+		}
+		sb.WriteRune(rune(*st[0]))
+	}
+	return sb.String()
+}
+
 func (s *stacks) readCrates(line string) error {
+	if len(line) > 1 && unicode.IsDigit(rune(line[1])) {
+		// the stack numbers line
+		return nil
+	}
+
 	nStacks := (len(line) + 1) / 4
 	for len(*s) < nStacks {
 		*s = append(*s, make(stack, 0))
 	}
 
-	for i := range *s {
+	for i := 0; i < nStacks; i++ {
 		r := rune(line[i*4+1])
 		if r != ' ' {
 			(*s)[i] = append((*s)[i], NewCrate(r))
@@ -112,7 +273,7 @@ func readStacks(scan *bufio.Scanner) (stacks, error) {
 		// len(line) > 0
 		err := s.readCrates(line)
 		if err != nil {
-			return nil, nil
+			return nil, err
 		}
 	}
 	if err := scan.Err(); err != nil {
@@ -122,7 +283,7 @@ func readStacks(scan *bufio.Scanner) (stacks, error) {
 	return nil, errors.New("wrong file format, no end of crate stacks")
 }
 
-func processFile(name string, moveFunc func(*stacks, int, int, int)) (string, error) {
+func processFile(name string, mover CrateMover) (string, error) {
 	file, err := os.Open(name)
 	if err != nil {
 		return "", err
@@ -136,22 +297,17 @@ func processFile(name string, moveFunc func(*stacks, int, int, int)) (string, er
 		return "", err
 	}
 
-	err = stacks.moveCrates(scan, moveFunc)
+	y := NewCrateYard(stacks, mover)
+	err = y.moveCrates(scan)
 	if err != nil {
 		return "", err
 	}
 
-	var sb strings.Builder
-	for _, st := range stacks {
-		r := rune(*st[0])
-		sb.WriteRune(r)
-	}
-
-	return sb.String(), nil
+	return y.s.tops(), nil
 }
 
 func main() {
-	res, err := processFile("data/part_one.txt", moveOne)
+	res, err := processFile("data/part_one.txt", NewCrateMover9000())
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -160,7 +316,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals("CMZ", res, "")
 
-	res, err = processFile("data/input.txt", moveOne)
+	res, err = processFile("data/input.txt", NewCrateMover9000())
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -169,7 +325,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals("LJSVLTWQM", res, "")
 
-	res, err = processFile("data/part_one.txt", moveTwo)
+	res, err = processFile("data/part_one.txt", NewCrateMover9001())
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -178,7 +334,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals("MCD", res, "")
 
-	res, err = processFile("data/input.txt", moveTwo)
+	res, err = processFile("data/input.txt", NewCrateMover9001())
 	if err != nil {
 		fmt.Println(err)
 		return
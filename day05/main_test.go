@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readDrawing(t *testing.T, name string) (string, stacks) {
+	data, err := os.ReadFile(name)
+	assert.Nil(t, err)
+	drawing, _, _ := strings.Cut(string(data), "\n\n")
+	drawing += "\n"
+
+	s, err := readStacks(bufio.NewScanner(strings.NewReader(string(data))))
+	assert.Nil(t, err)
+	return drawing, s
+}
+
+func TestDrawingRoundTrip(t *testing.T) {
+	for _, name := range []string{"data/part_one.txt", "data/input.txt"} {
+		drawing, s := readDrawing(t, name)
+		assert.Equal(t, drawing, s.drawing())
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	drawing, s := readDrawing(t, "data/part_one.txt")
+	y := NewCrateYard(s, NewCrane(2))
+
+	assert.Nil(t, y.apply(1, 1, 0))
+	assert.Nil(t, y.apply(3, 0, 2))
+	// lifts of two crates: D,N first, then Z on top
+	assert.Equal(t, " CZ", y.s.tops())
+	assert.Equal(t, "Z,D,N,P,", y.s[2].String())
+	after := y.s.drawing()
+
+	assert.Nil(t, y.undo())
+	assert.Nil(t, y.undo())
+	assert.NotNil(t, y.undo())
+	assert.Equal(t, drawing, y.s.drawing())
+
+	assert.Nil(t, y.redo())
+	assert.Nil(t, y.redo())
+	assert.NotNil(t, y.redo())
+	assert.Equal(t, after, y.s.drawing())
+
+	assert.NotNil(t, y.apply(5, 0, 1))
+	assert.NotNil(t, y.apply(1, 0, 3))
+}
+
+func TestMoveCount(t *testing.T) {
+	drawing, s := readDrawing(t, "data/part_one.txt")
+	y := NewCrateYard(s, NewCrateMover9001())
+
+	// nothing is taken and nothing moves
+	assert.NotNil(t, y.apply(-1, 0, 1))
+	assert.NotNil(t, y.apply(0, 0, 1))
+	assert.NotNil(t, NewCrateMover9000().move(s, -2, 1, 0))
+	assert.Equal(t, drawing, y.s.drawing())
+	assert.NotNil(t, y.undo())
+}
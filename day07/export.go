@@ -0,0 +1,278 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (e *entry) sortedChildren() []*entry {
+	res := make([]*entry, 0, len(e.children))
+	for _, ch := range e.children {
+		res = append(res, ch)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
+// walk visits the entries depth first, children in name order, so that the
+// exports are deterministic.
+func (e *entry) walk(relPath string, visit func(e *entry, relPath string) error) error {
+	if err := visit(e, relPath); err != nil {
+		return err
+	}
+	for _, ch := range e.sortedChildren() {
+		if err := ch.walk(path.Join(relPath, ch.name), visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paxRecord formats a PAX extended header record, "<length> <key>=<value>\n",
+// the length counting itself.
+func paxRecord(key, value string) string {
+	rec := " " + key + "=" + value + "\n"
+	n := len(rec)
+	for {
+		l := len(strconv.Itoa(n)) + len(rec)
+		if l == n {
+			break
+		}
+		n = l
+	}
+	return strconv.Itoa(n) + rec
+}
+
+const tarBlockSize = 512
+
+// tarPadded returns data zero padded to whole tar blocks.
+func tarPadded(data string) []byte {
+	b := make([]byte, (len(data)+tarBlockSize-1)/tarBlockSize*tarBlockSize)
+	copy(b, data)
+	return b
+}
+
+// paxHeaderBlock returns a ustar header block for a PAX extended header of
+// size bytes. archive/tar refuses to write those, and drops GNU sparse
+// records from the ones it writes itself.
+func paxHeaderBlock(name string, size int) []byte {
+	b := make([]byte, tarBlockSize)
+	copy(b[0:100], name)
+	copy(b[100:], "0000644\x00")                  // mode
+	copy(b[108:], "0000000\x00")                  // uid
+	copy(b[116:], "0000000\x00")                  // gid
+	copy(b[124:], fmt.Sprintf("%011o\x00", size)) // size
+	copy(b[136:], "00000000000\x00")              // mtime
+	copy(b[148:], "        ")                     // checksum, blank while summing
+	b[156] = tar.TypeXHeader
+	copy(b[257:], "ustar\x0000")
+	var sum int
+	for _, c := range b {
+		sum += int(c)
+	}
+	copy(b[148:], fmt.Sprintf("%06o\x00 ", sum))
+	return b
+}
+
+// writeSparse writes an empty file of the size as a GNU sparse entry, format
+// 1.0: a PAX header with the real name and size, then a stored file holding
+// only the sparse map. The map lists one empty data segment at the end, so
+// the whole file is a hole, and takes four blocks whatever its size.
+func writeSparse(w io.Writer, tw *tar.Writer, n int, name string, size int) error {
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	records := paxRecord("GNU.sparse.major", "1") +
+		paxRecord("GNU.sparse.minor", "0") +
+		paxRecord("GNU.sparse.name", name) +
+		paxRecord("GNU.sparse.realsize", strconv.Itoa(size))
+	if _, err := w.Write(paxHeaderBlock(fmt.Sprintf("PaxHeaders.%d/file", n), len(records))); err != nil {
+		return err
+	}
+	if _, err := w.Write(tarPadded(records)); err != nil {
+		return err
+	}
+
+	// the stored name stays short, so that archive/tar needs no PAX header
+	// of its own
+	sparseMap := tarPadded(fmt.Sprintf("1\n%d\n0\n", size))
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     fmt.Sprintf("GNUSparseFile.%d/file", n),
+		Mode:     0644,
+		Size:     int64(len(sparseMap)),
+		Format:   tar.FormatUSTAR,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(sparseMap)
+	return err
+}
+
+// writeTar writes the tree as a tar stream, files as sparse entries of their
+// recorded size that hold no data, readable by archive/tar and GNU tar.
+func (f *fs) writeTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	var files int
+	err := f.root.walk(".", func(e *entry, relPath string) error {
+		if e == f.root {
+			return nil
+		}
+		if e.t == dir {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     relPath + "/",
+				Mode:     0755,
+			})
+		}
+		if e.size == 0 {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     relPath,
+				Mode:     0644,
+			})
+		}
+		files++
+		return writeSparse(w, tw, files, relPath, e.size)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// writeDir recreates the tree under root. Files are truncated to their
+// recorded size, which leaves them sparse on most filesystems.
+func (f *fs) writeDir(root string) error {
+	return f.root.walk(".", func(e *entry, relPath string) error {
+		p := filepath.Join(root, filepath.FromSlash(relPath))
+		if e.t == dir {
+			return os.MkdirAll(p, 0755)
+		}
+		file, err := os.Create(p)
+		if err != nil {
+			return err
+		}
+		if err = file.Truncate(int64(e.size)); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	})
+}
+
+type jsonEntry struct {
+	Name     string       `json:"name"`
+	Type     string       `json:"type"`
+	Size     int          `json:"size"`
+	Children []*jsonEntry `json:"children,omitempty"`
+}
+
+func (e *entry) toJSON() *jsonEntry {
+	je := &jsonEntry{
+		Name: e.name,
+		Type: "file",
+		Size: e.size,
+	}
+	if e.t == dir {
+		je.Type = "dir"
+		for _, ch := range e.sortedChildren() {
+			je.Children = append(je.Children, ch.toJSON())
+		}
+	}
+	return je
+}
+
+func (f *fs) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f.root.toJSON())
+}
+
+// transcriptFromDir walks a real directory tree and produces the "$ cd" /
+// "$ ls" transcript that execLine consumes. Anything other than regular files
+// and directories is skipped.
+func transcriptFromDir(fsys iofs.FS) ([]string, error) {
+	lines := []string{"$ cd /"}
+	var cwd []string
+
+	err := iofs.WalkDir(fsys, ".", func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		var target []string
+		if p != "." {
+			target = strings.Split(p, "/")
+		}
+		// climb up to the common ancestor, then descend into the target
+		common := 0
+		for common < len(cwd) && common < len(target) && cwd[common] == target[common] {
+			common++
+		}
+		for i := len(cwd); i > common; i-- {
+			lines = append(lines, "$ cd ..")
+		}
+		for _, name := range target[common:] {
+			lines = append(lines, "$ cd "+name)
+		}
+		cwd = target
+
+		entries, err := iofs.ReadDir(fsys, p)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, "$ ls")
+		for _, de := range entries {
+			if strings.Contains(de.Name(), " ") {
+				return errors.New("cannot represent name with spaces " + path.Join(p, de.Name()))
+			}
+			switch {
+			case de.IsDir():
+				lines = append(lines, "dir "+de.Name())
+			case de.Type().IsRegular():
+				info, err := de.Info()
+				if err != nil {
+					return err
+				}
+				lines = append(lines, fmt.Sprintf("%d %s", info.Size(), de.Name()))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+func importDir(fsys iofs.FS) (*fs, error) {
+	lines, err := transcriptFromDir(fsys)
+	if err != nil {
+		return nil, err
+	}
+	f := NewStrictFs()
+	for _, l := range lines {
+		if err = f.execLine(l); err != nil {
+			return nil, err
+		}
+	}
+	if err = f.err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
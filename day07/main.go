@@ -78,10 +78,77 @@ func NewEntry(parent *entry, t entryType, name string, size int) *entry {
 	return e
 }
 
+type transcriptErrorKind uint8
+
+const (
+	malformedLine transcriptErrorKind = iota
+	unknownCommand
+	unknownDir
+	conflictingEntry
+	duplicateListing
+	cdPastRoot
+)
+
+func (k transcriptErrorKind) String() string {
+	switch k {
+	case malformedLine:
+		return "malformed line"
+	case unknownCommand:
+		return "unknown command"
+	case unknownDir:
+		return "unknown dir"
+	case conflictingEntry:
+		return "conflicting entry"
+	case duplicateListing:
+		return "duplicate listing"
+	case cdPastRoot:
+		return "cd past root"
+	}
+	return "unknown error"
+}
+
+// transcriptError describes a transcript line that does not match the tree
+// built so far.
+type transcriptError struct {
+	kind transcriptErrorKind
+	line int
+	text string
+	msg  string
+}
+
+func (e *transcriptError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s (%q)", e.line, e.kind, e.msg, e.text)
+}
+
+type transcriptErrors []*transcriptError
+
+func (te transcriptErrors) Error() string {
+	var sb strings.Builder
+	for i, e := range te {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(e.Error())
+	}
+	return sb.String()
+}
+
 type fs struct {
 	root        *entry
 	pwd         *entry
 	dirSizeHook func(e *entry)
+
+	// strict mode collects every inconsistency in the transcript instead of
+	// stopping at the first one, and skips the offending lines
+	strict bool
+	listed map[*entry]struct{}
+	errs   transcriptErrors
+	// relisting is set while reading the entries of a duplicate listing,
+	// which may only repeat the entries already known
+	relisting bool
+
+	lineNum int
+	line    string
 }
 
 func NewFs() *fs {
@@ -93,6 +160,30 @@ func NewFs() *fs {
 	return f
 }
 
+func NewStrictFs() *fs {
+	f := NewFs()
+	f.strict = true
+	f.listed = make(map[*entry]struct{})
+	return f
+}
+
+func (f *fs) fail(kind transcriptErrorKind, msg string) *transcriptError {
+	return &transcriptError{
+		kind: kind,
+		line: f.lineNum,
+		text: f.line,
+		msg:  msg,
+	}
+}
+
+// err returns all inconsistencies collected in strict mode, or nil.
+func (f *fs) err() error {
+	if len(f.errs) == 0 {
+		return nil
+	}
+	return f.errs
+}
+
 func (f *fs) updateParentsSize(p *entry, size int) {
 	parent := p
 	for parent != nil {
@@ -106,20 +197,24 @@ func (f *fs) updateParentsSize(p *entry, size int) {
 
 func (f *fs) mkfile(args []string) error {
 	if len(args) != 2 {
-		return errors.New("wrong mkfile args " + fmt.Sprint(args))
+		return f.fail(malformedLine, "wrong mkfile args "+fmt.Sprint(args))
 	}
 	size, err := strconv.Atoi(args[0])
 	if err != nil {
-		return err
+		return f.fail(malformedLine, "wrong file size "+args[0])
 	}
 	name := args[1]
+	if err := f.checkRelisted(name); err != nil {
+		return err
+	}
 	if entry, ok := f.pwd.children[name]; ok {
 		// already exists
 		if entry.t != file {
-			return errors.New("directory already exists with name " + name)
+			return f.fail(conflictingEntry, "directory already exists with name "+name)
 		}
 		if entry.size != size {
-			return errors.New("entry exists with name " + name + " and size " + args[0])
+			return f.fail(conflictingEntry,
+				fmt.Sprintf("file %s already exists with size %d", name, entry.size))
 		}
 		// entry matches, noop
 		return nil
@@ -135,12 +230,15 @@ func (f *fs) mkfile(args []string) error {
 }
 
 func (f *fs) mkdir(name string) error {
+	if err := f.checkRelisted(name); err != nil {
+		return err
+	}
 	if d, ok := f.pwd.children[name]; ok {
 		if d.t == dir {
 			// already exists
 			return nil
 		}
-		return errors.New("cannot make dir, file name already exists " + name)
+		return f.fail(conflictingEntry, "cannot make dir, file name already exists "+name)
 	}
 	e := NewEntry(f.pwd, dir, name, 0)
 	f.pwd.children[name] = e
@@ -155,36 +253,90 @@ func (f *fs) cd(name string) error {
 	case "/":
 		f.pwd = f.root
 	case "..":
+		if f.pwd.parent == nil {
+			if f.strict {
+				return f.fail(cdPastRoot, "already at root")
+			}
+			// like a shell, stay at root
+			return nil
+		}
 		f.pwd = f.pwd.parent
 	default:
 		entry, ok := f.pwd.children[name]
 		if !ok {
-			return errors.New("dir not found " + name)
+			return f.fail(unknownDir, "dir not found "+name)
 		}
 		if entry.t != dir {
-			return errors.New("cannot cd into a file " + name)
+			return f.fail(unknownDir, "cannot cd into a file "+name)
 		}
 		f.pwd = entry
 	}
 	return nil
 }
 
-func (f *fs) execLine(line string) error {
-	args := strings.Split(line, " ")
+func (f *fs) ls() error {
+	if !f.strict {
+		return nil
+	}
+	if _, ok := f.listed[f.pwd]; ok {
+		f.relisting = true
+		return f.fail(duplicateListing, "dir already listed "+f.pwd.name)
+	}
+	f.listed[f.pwd] = struct{}{}
+	return nil
+}
+
+// checkRelisted fails for an entry of a duplicate listing that the first
+// listing did not have.
+func (f *fs) checkRelisted(name string) error {
+	if !f.relisting {
+		return nil
+	}
+	if _, ok := f.pwd.children[name]; !ok {
+		return f.fail(conflictingEntry, "entry missing from the first listing "+name)
+	}
+	return nil
+}
+
+func (f *fs) exec(args []string) error {
 	if len(args) < 2 {
-		return errors.New("too few entries in line " + line)
+		return f.fail(malformedLine, "too few entries in line")
+	}
+	if args[0] == "$" {
+		f.relisting = false
 	}
 	switch {
 	case args[0] == "$" && args[1] == "cd":
+		if len(args) != 3 {
+			return f.fail(malformedLine, "wrong cd args "+fmt.Sprint(args[2:]))
+		}
 		return f.cd(args[2])
 	case args[0] == "$" && args[1] == "ls":
-		// noop
+		return f.ls()
+	case args[0] == "$":
+		return f.fail(unknownCommand, "unknown command "+args[1])
 	case args[0] == "dir":
 		return f.mkdir(args[1])
 	default:
 		return f.mkfile(args)
 	}
-	return nil
+}
+
+func (f *fs) execLine(line string) error {
+	f.lineNum++
+	f.line = line
+
+	err := f.exec(strings.Split(line, " "))
+	if err == nil || !f.strict {
+		return err
+	}
+	var te *transcriptError
+	if errors.As(err, &te) {
+		// the offending line is skipped, keep going to report everything
+		f.errs = append(f.errs, te)
+		return nil
+	}
+	return err
 }
 
 // An IntHeap is a min-heap of ints.
@@ -245,21 +397,34 @@ func findSmallDirs(f *fs) (int, error) {
 	return sum, nil
 }
 
-func processFile(name string, resCalc func(*fs) (int, error)) (int, error) {
+func readFs(name string, strict bool) (*fs, error) {
 	f := NewFs()
+	if strict {
+		f = NewStrictFs()
+	}
 
 	err := input.ReadFileLines(name, func(line string) error {
 		return f.execLine(line)
 	})
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if err = f.err(); err != nil {
+		return nil, err
 	}
+	return f, nil
+}
 
+func processFile(name string, strict bool, resCalc func(*fs) (int, error)) (int, error) {
+	f, err := readFs(name, strict)
+	if err != nil {
+		return 0, err
+	}
 	return resCalc(f)
 }
 
 func main() {
-	res, err := processFile("data/part_one.txt", findSmallDirs)
+	res, err := processFile("data/part_one.txt", true, findSmallDirs)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -268,7 +433,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals(95437, res, "")
 
-	res, err = processFile("data/input.txt", findSmallDirs)
+	res, err = processFile("data/input.txt", true, findSmallDirs)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -277,7 +442,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals(1845346, res, "")
 
-	res, err = processFile("data/part_one.txt", findMatchingDir)
+	res, err = processFile("data/part_one.txt", true, findMatchingDir)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -286,7 +451,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals(24933642, res, "")
 
-	res, err = processFile("data/input.txt", findMatchingDir)
+	res, err = processFile("data/input.txt", true, findMatchingDir)
 	if err != nil {
 		fmt.Println(err)
 		return
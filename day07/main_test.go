@@ -0,0 +1,194 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func execLines(f *fs, lines ...string) error {
+	for _, l := range lines {
+		if err := f.execLine(l); err != nil {
+			return err
+		}
+	}
+	return f.err()
+}
+
+func TestStrictTranscript(t *testing.T) {
+	f := NewStrictFs()
+	err := execLines(f,
+		"$ cd /",
+		"$ ls",
+		"dir a",
+		"10 b",
+		"$ cd ..",
+		"$ cd a",
+		"$ ls",
+		"20 c",
+		"$ cd ..",
+		"$ ls",
+		"10 b",
+		"30 b",
+		"$ rm b",
+	)
+
+	var errs transcriptErrors
+	assert.True(t, errors.As(err, &errs))
+	assert.Equal(t, 4, len(errs))
+
+	assert.Equal(t, cdPastRoot, errs[0].kind)
+	assert.Equal(t, 5, errs[0].line)
+	assert.Equal(t, duplicateListing, errs[1].kind)
+	assert.Equal(t, 10, errs[1].line)
+	assert.Equal(t, conflictingEntry, errs[2].kind)
+	assert.Equal(t, 12, errs[2].line)
+	assert.Equal(t, unknownCommand, errs[3].kind)
+	assert.Equal(t, 13, errs[3].line)
+
+	// offending lines are skipped, sizes are not double counted
+	assert.Equal(t, 30, f.root.size)
+	assert.Equal(t, f.root, f.pwd)
+}
+
+func TestStrictRelisting(t *testing.T) {
+	f := NewStrictFs()
+	err := execLines(f,
+		"$ cd /",
+		"$ ls",
+		"dir a",
+		"1 b",
+		"$ ls",
+		"dir a",
+		"1 b",
+		"dir c",
+		"2 d",
+		"$ cd a",
+		"$ ls",
+		"3 e",
+	)
+
+	var errs transcriptErrors
+	assert.True(t, errors.As(err, &errs))
+	assert.Equal(t, 3, len(errs))
+	assert.Equal(t, duplicateListing, errs[0].kind)
+	assert.Equal(t, 5, errs[0].line)
+	assert.Equal(t, conflictingEntry, errs[1].kind)
+	assert.Equal(t, 8, errs[1].line)
+	assert.Equal(t, conflictingEntry, errs[2].kind)
+	assert.Equal(t, 9, errs[2].line)
+
+	// the extra entries are not added, the next listing is read as usual
+	assert.NotContains(t, f.root.children, "c")
+	assert.NotContains(t, f.root.children, "d")
+	assert.Equal(t, 4, f.root.size)
+}
+
+func TestLenientTranscript(t *testing.T) {
+	f := NewFs()
+	err := execLines(f,
+		"$ cd /",
+		"$ cd ..",
+		"$ ls",
+		"10 b",
+		"$ ls",
+		"10 b",
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, f.root.size)
+
+	err = f.execLine("20 b")
+	var te *transcriptError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, conflictingEntry, te.kind)
+	assert.Equal(t, 7, te.line)
+}
+
+func TestStrictInput(t *testing.T) {
+	res, err := processFile("data/input.txt", true, findSmallDirs)
+	assert.Nil(t, err)
+	assert.Equal(t, 1845346, res)
+}
+
+func TestDirRoundTrip(t *testing.T) {
+	f, err := readFs("data/part_one.txt", true)
+	assert.Nil(t, err)
+
+	root := t.TempDir()
+	assert.Nil(t, f.writeDir(root))
+
+	f2, err := importDir(os.DirFS(root))
+	assert.Nil(t, err)
+	assert.Equal(t, f.root.size, f2.root.size)
+
+	var want, got bytes.Buffer
+	assert.Nil(t, f.writeJSON(&want))
+	assert.Nil(t, f2.writeJSON(&got))
+	assert.Equal(t, want.String(), got.String())
+}
+
+func TestTranscriptFromDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b/c.txt": {Data: make([]byte, 3)},
+		"a/d":       {Data: make([]byte, 5)},
+		"e":         {Data: make([]byte, 7)},
+	}
+	lines, err := transcriptFromDir(fsys)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"$ cd /",
+		"$ ls",
+		"dir a",
+		"7 e",
+		"$ cd a",
+		"$ ls",
+		"dir b",
+		"5 d",
+		"$ cd b",
+		"$ ls",
+		"3 c.txt",
+	}, lines)
+}
+
+func TestWriteTar(t *testing.T) {
+	f, err := readFs("data/part_one.txt", true)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, f.writeTar(&buf))
+	// sparse entries hold no data, whatever the sizes
+	assert.Less(t, buf.Len(), 64*1024)
+
+	tr := tar.NewReader(&buf)
+	var dirs, size int
+	files := map[string]int64{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		if h.Typeflag == tar.TypeDir {
+			dirs++
+			continue
+		}
+		files[h.Name] = h.Size
+		size += int(h.Size)
+		if h.Name == "a/e/i" {
+			data, err := io.ReadAll(tr)
+			assert.Nil(t, err)
+			assert.Equal(t, make([]byte, 584), data)
+		}
+	}
+	assert.Equal(t, 3, dirs)
+	assert.Equal(t, 10, len(files))
+	assert.Equal(t, int64(14848514), files["b.txt"])
+	assert.Equal(t, int64(584), files["a/e/i"])
+	assert.Equal(t, f.root.size, size)
+}
@@ -22,20 +22,48 @@ type size struct {
 	topRight   point
 }
 
+func (s *size) extend(k knot, pad int64) {
+	if k.x-pad < s.bottomLeft.x {
+		s.bottomLeft.x = k.x - pad
+	}
+	if k.x+pad > s.topRight.x {
+		s.topRight.x = k.x + pad
+	}
+	if k.y-pad < s.bottomLeft.y {
+		s.bottomLeft.y = k.y - pad
+	}
+	if k.y+pad > s.topRight.y {
+		s.topRight.y = k.y + pad
+	}
+}
+
 type rope struct {
 	knots   []knot
 	count   int64
 	visited map[knot]struct{}
 	size    size
+
+	// positions visited and single steps taken by every knot
+	knotVisited []map[knot]struct{}
+	knotMoves   []int
+	// positions of all the knots after every step of the head
+	frames [][]knot
 }
 
 func NewRope(knotCount int64) *rope {
 	r := &rope{}
 	r.count = knotCount
 	r.knots = make([]knot, knotCount)
-	r.visited = map[knot]struct{}{
-		r.knots[r.count-1]: {},
+	r.knotMoves = make([]int, knotCount)
+	r.knotVisited = make([]map[knot]struct{}, knotCount)
+	for i := range r.knotVisited {
+		r.knotVisited[i] = map[knot]struct{}{
+			r.knots[i]: {},
+		}
 	}
+	r.visited = r.knotVisited[r.count-1]
+	r.size.extend(noOp, 2)
+	r.recordFrame()
 	return r
 }
 
@@ -52,44 +80,65 @@ func (r *rope) move(dir string, n int64) {
 		case "D":
 			r.knots[0].y--
 		}
+		r.visitKnot(0)
 		r.pullRope()
+		r.recordFrame()
 	}
 }
 
 var noOp knot
 
-func (r *rope) visitTail() {
-	pad := int64(2)
-	tail := &r.knots[r.count-1]
-	r.visited[*tail] = struct{}{}
-	if tail.x-pad < r.size.bottomLeft.x {
-		r.size.bottomLeft.x = tail.x - pad
-	}
-	if tail.x+pad > r.size.topRight.x {
-		r.size.topRight.x = tail.x + pad
-	}
-	if tail.y-pad < r.size.bottomLeft.y {
-		r.size.bottomLeft.y = tail.y - pad
-	}
-	if tail.y+pad > r.size.topRight.y {
-		r.size.topRight.y = tail.y + pad
-	}
+func (r *rope) visitKnot(i int) {
+	k := r.knots[i]
+	r.knotVisited[i][k] = struct{}{}
+	r.knotMoves[i]++
+	r.size.extend(k, 2)
+}
+
+func (r *rope) recordFrame() {
+	r.frames = append(r.frames, input.CopySlice(r.knots))
 }
 
 func (r *rope) pullRope() {
 	for i := 1; i < len(r.knots); i++ {
-		isTail := int64(i) == r.count-1
 		prev, cur := &r.knots[i-1], &r.knots[i]
 		for dir := prev.pull(cur); dir != noOp; dir = prev.pull(cur) {
 			cur.x += dir.x
 			cur.y += dir.y
-			if isTail {
-				r.visitTail()
-			}
+			r.visitKnot(i)
 		}
 	}
 }
 
+type knotStats struct {
+	knot    int
+	visited int
+	moves   int
+}
+
+// stats returns the number of distinct positions and single steps for every
+// knot, head first.
+func (r *rope) stats() []knotStats {
+	res := make([]knotStats, r.count)
+	for i := range res {
+		res[i] = knotStats{
+			knot:    i,
+			visited: len(r.knotVisited[i]),
+			moves:   r.knotMoves[i],
+		}
+	}
+	return res
+}
+
+// trajectory returns the position of knot i in every frame.
+func (r *rope) trajectory(i int) []knot {
+	res := make([]knot, len(r.frames))
+	for f, knots := range r.frames {
+		res[f] = knots[i]
+	}
+	return res
+}
+
 func (k *knot) pull(other *knot) knot {
 	res := knot{
 		x: k.x - other.x,
@@ -152,17 +201,7 @@ func (r *rope) runFile(name string) error {
 }
 
 func (r *rope) printVisited() {
-	for i := r.size.topRight.y; i >= r.size.bottomLeft.y; i-- {
-		var line strings.Builder
-		for j := r.size.bottomLeft.x; j <= r.size.topRight.x; j++ {
-			if _, exists := r.visited[knot{x: int64(j), y: int64(i)}]; exists {
-				line.WriteString("#")
-			} else {
-				line.WriteString(".")
-			}
-		}
-		fmt.Println(line.String())
-	}
+	fmt.Print(r.visitedString(int(r.count - 1)))
 }
 
 func main() {
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"image/gif"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	r := NewRope(10)
+	assert.Nil(t, r.runFile("data/part_two.txt"))
+
+	stats := r.stats()
+	assert.Equal(t, 10, len(stats))
+	assert.Equal(t, 36, stats[9].visited)
+	assert.Equal(t, len(r.visited), stats[9].visited)
+
+	// the head moves one step per frame
+	assert.Equal(t, len(r.frames)-1, stats[0].moves)
+	for i := 1; i < len(stats); i++ {
+		assert.LessOrEqual(t, stats[i].visited, stats[i-1].visited)
+	}
+
+	tail := r.trajectory(9)
+	assert.Equal(t, len(r.frames), len(tail))
+	assert.Equal(t, knot{}, tail[0])
+	assert.Equal(t, r.knots[9], tail[len(tail)-1])
+}
+
+func TestFrameString(t *testing.T) {
+	r := NewRope(10)
+	r.move("R", 4)
+
+	lines := strings.Split(r.frameString(4), "\n")
+	// y=0 row, with the box padded by 2 around all the knots
+	assert.Equal(t, "..4321H..", lines[2])
+	assert.Equal(t, 5, len(r.frames))
+}
+
+func TestFrameStringLongRope(t *testing.T) {
+	r := NewRope(40)
+	r.move("R", 39)
+
+	lines := strings.Split(r.frameString(39), "\n")
+	assert.Equal(t, "..****zyxwvutsrqponmlkjihgfedcba987654321H..", lines[2])
+}
+
+func TestWriteGIF(t *testing.T) {
+	r := NewRope(10)
+	assert.Nil(t, r.runFile("data/part_two.txt"))
+
+	var buf bytes.Buffer
+	assert.Nil(t, r.writeGIF(&buf, gifOptions{scale: 2, delay: 1, every: 10}))
+
+	anim, err := gif.DecodeAll(&buf)
+	assert.Nil(t, err)
+	// every 10th frame, plus the last one
+	frames := (len(r.frames) + 9) / 10
+	if (len(r.frames)-1)%10 != 0 {
+		frames++
+	}
+	assert.Equal(t, frames, len(anim.Image))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strconv"
+	"strings"
+
+	"kfet.org/aoc_common/calc"
+)
+
+// knotLabels is the number of knots with a label of their own, the head and
+// one base 36 digit each for the others.
+const knotLabels = 36
+
+// knotRune labels the knots as in the puzzle: H for the head, then 1, 2, ...
+// up to z. The knots past z would reuse the labels, so they are all drawn as
+// a plain '*'.
+func knotRune(i int) rune {
+	switch {
+	case i == 0:
+		return 'H'
+	case i >= knotLabels:
+		return '*'
+	}
+	return rune(strconv.FormatInt(int64(i), 36)[0])
+}
+
+func (r *rope) render(cell func(p knot) rune) string {
+	var sb strings.Builder
+	for y := r.size.topRight.y; y >= r.size.bottomLeft.y; y-- {
+		for x := r.size.bottomLeft.x; x <= r.size.topRight.x; x++ {
+			sb.WriteRune(cell(knot{x: x, y: y}))
+		}
+		sb.WriteRune('\n')
+	}
+	return sb.String()
+}
+
+// visitedString draws the positions visited by knot i.
+func (r *rope) visitedString(i int) string {
+	return r.render(func(p knot) rune {
+		if _, ok := r.knotVisited[i][p]; ok {
+			return '#'
+		}
+		return '.'
+	})
+}
+
+// frameString draws all the knots in frame f, lower knots hidden by higher
+// ones like in the puzzle description.
+func (r *rope) frameString(f int) string {
+	pos := make(map[knot]int)
+	knots := r.frames[f]
+	for i := len(knots) - 1; i >= 0; i-- {
+		pos[knots[i]] = i
+	}
+	return r.render(func(p knot) rune {
+		if i, ok := pos[p]; ok {
+			return knotRune(i)
+		}
+		if p == noOp {
+			return 's'
+		}
+		return '.'
+	})
+}
+
+func (r *rope) writeFrames(w io.Writer) error {
+	for f := range r.frames {
+		_, err := fmt.Fprintf(w, "== Step %d ==\n\n%s\n", f, r.frameString(f))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type gifOptions struct {
+	// pixels per cell
+	scale int
+	// delay between frames, in 100ths of a second
+	delay int
+	// render only every n-th frame
+	every int
+}
+
+var defaultGifOptions = gifOptions{
+	scale: 4,
+	delay: 5,
+	every: 1,
+}
+
+// ropePalette: background, start, visited by the tail, then the knots fading
+// from the head to the tail.
+func ropePalette(knotCount int) color.Palette {
+	p := color.Palette{
+		color.RGBA{0x0f, 0x0f, 0x23, 0xff},
+		color.RGBA{0xff, 0xff, 0x66, 0xff},
+		color.RGBA{0x33, 0x33, 0x55, 0xff},
+	}
+	for i := 0; i < knotCount && len(p) < 256; i++ {
+		shade := uint8(0xff - 0xc0*i/knotCount)
+		p = append(p, color.RGBA{shade, shade / 2, 0x20, 0xff})
+	}
+	return p
+}
+
+// writeGIF renders the frames as an animated GIF, with the tail trail drawn
+// as the rope moves.
+func (r *rope) writeGIF(w io.Writer, opts gifOptions) error {
+	if opts.scale <= 0 || opts.every <= 0 {
+		return fmt.Errorf("wrong gif options %+v", opts)
+	}
+	width := int(r.size.topRight.x-r.size.bottomLeft.x+1) * opts.scale
+	height := int(r.size.topRight.y-r.size.bottomLeft.y+1) * opts.scale
+	palette := ropePalette(int(r.count))
+
+	fill := func(img *image.Paletted, k knot, c uint8) {
+		x0 := int(k.x-r.size.bottomLeft.x) * opts.scale
+		y0 := int(r.size.topRight.y-k.y) * opts.scale
+		for y := y0; y < y0+opts.scale; y++ {
+			for x := x0; x < x0+opts.scale; x++ {
+				img.SetColorIndex(x, y, c)
+			}
+		}
+	}
+
+	anim := &gif.GIF{}
+	tail := int(r.count - 1)
+	trail := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+	for f, knots := range r.frames {
+		fill(trail, knots[tail], 2)
+		if f%opts.every != 0 && f != len(r.frames)-1 {
+			continue
+		}
+		img := image.NewPaletted(trail.Rect, palette)
+		copy(img.Pix, trail.Pix)
+		fill(img, noOp, 1)
+		for i := len(knots) - 1; i >= 0; i-- {
+			c := calc.Min(3+i, len(palette)-1)
+			fill(img, knots[i], uint8(c))
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, opts.delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
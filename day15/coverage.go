@@ -0,0 +1,132 @@
+package main
+
+import (
+	"sort"
+
+	"kfet.org/aoc_common/calc"
+)
+
+type point struct {
+	x, y int
+}
+
+// searchArea is a rectangle, bounds inclusive.
+type searchArea struct {
+	x1, y1, x2, y2 int
+}
+
+// In the rotated coordinates u = x+y, v = x-y a sensor's diamond is a square
+// [u-dist, u+dist] x [v-dist, v+dist], so the union of the coverage splits
+// into a small grid of cells, each either fully covered or not at all.
+type coverageEngine struct {
+	sensors []*sensor
+}
+
+func NewCoverageEngine(sensors []*sensor) *coverageEngine {
+	return &coverageEngine{sensors: sensors}
+}
+
+func (s *sensor) uv() (int, int) {
+	return s.x + s.y, s.x - s.y
+}
+
+// breakpoints returns the sorted, unique cell edges within [lo, hi). Each
+// cell spans from one edge up to the next one, exclusive.
+func breakpoints(edges []int, lo, hi int) []int {
+	res := []int{lo, hi}
+	for _, e := range edges {
+		if e > lo && e < hi {
+			res = append(res, e)
+		}
+	}
+	sort.Ints(res)
+	uniq := res[:1]
+	for _, e := range res[1:] {
+		if e != uniq[len(uniq)-1] {
+			uniq = append(uniq, e)
+		}
+	}
+	return uniq
+}
+
+func (e *coverageEngine) covered(u, v int) bool {
+	for _, s := range e.sensors {
+		su, sv := s.uv()
+		if calc.Abs(u-su) <= s.dist && calc.Abs(v-sv) <= s.dist {
+			return true
+		}
+	}
+	return false
+}
+
+// uncovered calls visit for every point of the area no sensor covers, until
+// visit returns false. The edges of the sensors split the area into up to
+// (2*sensors+1)^2 cells, each checked against every sensor, so the cost is
+// the number of cells times the number of sensors, plus the number of
+// uncovered points.
+func (e *coverageEngine) uncovered(a searchArea, visit func(p point) bool) {
+	if a.x1 > a.x2 || a.y1 > a.y2 {
+		return
+	}
+	uEdges := make([]int, 0, 2*len(e.sensors))
+	vEdges := make([]int, 0, 2*len(e.sensors))
+	for _, s := range e.sensors {
+		su, sv := s.uv()
+		uEdges = append(uEdges, su-s.dist, su+s.dist+1)
+		vEdges = append(vEdges, sv-s.dist, sv+s.dist+1)
+	}
+	us := breakpoints(uEdges, a.x1+a.y1, a.x2+a.y2+1)
+	vs := breakpoints(vEdges, a.x1-a.y2, a.x2-a.y1+1)
+
+	for i := 0; i < len(us)-1; i++ {
+		for j := 0; j < len(vs)-1; j++ {
+			ua, ub := us[i], us[i+1]-1
+			va, vb := vs[j], vs[j+1]-1
+			if e.covered(ua, va) {
+				continue
+			}
+			if !visitCell(a, ua, ub, va, vb, visit) {
+				return
+			}
+		}
+	}
+}
+
+// visitCell enumerates the points of the rotated cell [ua, ub] x [va, vb]
+// that fall inside the area.
+func visitCell(a searchArea, ua, ub, va, vb int, visit func(p point) bool) bool {
+	// the range of u where the cell and the area overlap
+	uLo := calc.Max(calc.Max(ua, va+2*a.y1), calc.Max(2*a.x1-vb, a.x1+a.y1))
+	uHi := calc.Min(calc.Min(ub, 2*a.x2-va), calc.Min(vb+2*a.y2, a.x2+a.y2))
+	for u := uLo; u <= uHi; u++ {
+		vLo := calc.Max(va, calc.Max(2*a.x1-u, u-2*a.y2))
+		vHi := calc.Min(vb, calc.Min(2*a.x2-u, u-2*a.y1))
+		if (vLo-u)%2 != 0 {
+			// u and v must have the same parity for x and y to be integers
+			vLo++
+		}
+		for v := vLo; v <= vHi; v += 2 {
+			if !visit(point{x: (u + v) / 2, y: (u - v) / 2}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// uncoveredPoints returns all the points of the area no sensor covers, sorted
+// by row.
+func (e *coverageEngine) uncoveredPoints(a searchArea) []point {
+	res := make([]point, 0)
+	e.uncovered(a, func(p point) bool {
+		res = append(res, p)
+		return true
+	})
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].y != res[j].y {
+			return res[i].y < res[j].y
+		}
+		return res[i].x < res[j].x
+	})
+	return res
+}
@@ -79,7 +79,7 @@ func processFile(name string, interestingRow int, searchSize int, partOne bool)
 	// beacons detected on the interesting row
 	mb := map[int]struct{}{}
 
-	ms := map[*sensor]struct{}{}
+	sensors := make([]*sensor, 0)
 
 	err := input.ReadFileLines(name, func(line string) error {
 		s, b, err := readSensor(line)
@@ -91,7 +91,7 @@ func processFile(name string, interestingRow int, searchSize int, partOne bool)
 			mb[b.x] = struct{}{}
 		}
 
-		ms[s] = struct{}{}
+		sensors = append(sensors, s)
 
 		rc, cover := s.rowCoverage(interestingRow)
 		if !cover {
@@ -123,32 +123,16 @@ func processFile(name string, interestingRow int, searchSize int, partOne bool)
 	}
 
 	// part two
-	isCovered := func(x, y int) (*sensor, bool) {
-		for s := range ms {
-			if s.pointCovered(x, y) {
-				return s, true
-			}
-		}
-		return nil, false
-	}
-
-	for y := 0; y < searchSize; y++ {
-		if y%1_000_000 == 0 {
-			fmt.Printf("Row %d ..\n", y)
-		}
-		for x := 0; x < searchSize; x++ {
-			if s, isCovered := isCovered(x, y); isCovered {
-				coverage, _ := s.rowCoverage(y)
-				// skip to the edge of this sensor coverage on the current row
-				// ^ this is the important optimizatio to solve the puzzle ^
-				x = coverage.x2
-				continue
-			}
-			return x*4_000_000 + y, nil
-		}
+	e := NewCoverageEngine(sensors)
+	var res *point
+	e.uncovered(searchArea{0, 0, searchSize, searchSize}, func(p point) bool {
+		res = &p
+		return false
+	})
+	if res == nil {
+		return 0, errors.New("beacon not found")
 	}
-
-	return 0, errors.New("beacon not found")
+	return res.x*4_000_000 + res.y, nil
 }
 
 func main() {
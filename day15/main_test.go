@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kfet.org/aoc_common/input"
+)
+
+func readSensors(t *testing.T, name string) []*sensor {
+	sensors := make([]*sensor, 0)
+	err := input.ReadFileLines(name, func(line string) error {
+		s, _, err := readSensor(line)
+		sensors = append(sensors, s)
+		return err
+	})
+	assert.Nil(t, err)
+	return sensors
+}
+
+func TestUncoveredPoints(t *testing.T) {
+	sensors := readSensors(t, "data/part_one.txt")
+	e := NewCoverageEngine(sensors)
+
+	assert.Equal(t, []point{{14, 11}}, e.uncoveredPoints(searchArea{0, 0, 20, 20}))
+
+	areas := []searchArea{
+		{-10, -10, 30, 30},
+		{-3, 5, 2, 40},
+		{25, -5, 31, 1},
+		{14, 11, 14, 11},
+		{5, 5, 4, 4},
+	}
+	for _, a := range areas {
+		brute := make([]point, 0)
+		for y := a.y1; y <= a.y2; y++ {
+			for x := a.x1; x <= a.x2; x++ {
+				covered := false
+				for _, s := range sensors {
+					covered = covered || s.pointCovered(x, y)
+				}
+				if !covered {
+					brute = append(brute, point{x, y})
+				}
+			}
+		}
+		assert.Equal(t, brute, e.uncoveredPoints(a), "%+v", a)
+	}
+}
+
+func TestPartTwo(t *testing.T) {
+	res, err := processFile("data/input.txt", 2_000_000, 4_000_000, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 13267474686239, res)
+}
+
+func TestSearchBoundary(t *testing.T) {
+	// the search area goes from 0 to searchSize, both included: each sensor
+	// leaves only the corner furthest from it uncovered
+	for _, tc := range []struct {
+		line string
+		want int
+	}{
+		{"Sensor at x=0, y=0: closest beacon is at x=7, y=0", 4*4_000_000 + 4},
+		{"Sensor at x=0, y=4: closest beacon is at x=0, y=-3", 4 * 4_000_000},
+		{"Sensor at x=4, y=0: closest beacon is at x=4, y=7", 4},
+	} {
+		fileName := t.TempDir() + "/sensors.txt"
+		assert.Nil(t, os.WriteFile(fileName, []byte(tc.line+"\n"), 0644))
+		res, err := processFile(fileName, 0, 4, false)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, res, tc.line)
+	}
+}
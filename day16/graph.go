@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// validate flags the valves referenced by tunnels but never defined, which
+// readValve creates with a rate of -1.
+func (m *mesh) validate() error {
+	undefined := map[*valve][]string{}
+	for _, v := range m.sortedValves() {
+		for _, t := range v.sortedTunnels() {
+			if t.rate < 0 {
+				undefined[t] = append(undefined[t], v.name)
+			}
+		}
+	}
+	if len(undefined) == 0 {
+		if _, found := (*m)["AA"]; !found {
+			return errors.New("start valve AA not defined")
+		}
+		return nil
+	}
+
+	msgs := []string{}
+	for v, refs := range undefined {
+		msgs = append(msgs, fmt.Sprintf("valve %s not defined, referenced by %s",
+			v.name, strings.Join(refs, ", ")))
+	}
+	sort.Strings(msgs)
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// writeDOT writes the mesh as a Graphviz digraph, rates in the node labels.
+// Tunnels leading both ways are drawn as a single edge.
+func (m *mesh) writeDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph valves {\n")
+	valves := m.sortedValves()
+	for _, v := range valves {
+		sb.WriteString(fmt.Sprintf("  %s [label=\"%s\\nrate=%d\"];\n", v.name, v.name, v.rate))
+	}
+	for _, v := range valves {
+		for _, t := range v.sortedTunnels() {
+			_, back := t.tunnels[v]
+			switch {
+			case !back:
+				sb.WriteString(fmt.Sprintf("  %s -> %s;\n", v.name, t.name))
+			case v.name < t.name:
+				sb.WriteString(fmt.Sprintf("  %s -> %s [dir=both];\n", v.name, t.name))
+			}
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeDistanceCSV writes the distances between the start valve and the
+// valves with a positive rate, the ones the solver works with. Unreachable
+// valves are left empty.
+func (m *mesh) writeDistanceCSV(w io.Writer, distMx valveToValveMatrix) error {
+	valves := []*valve{}
+	for _, v := range m.sortedValves() {
+		if v.name == "AA" || v.rate > 0 {
+			valves = append(valves, v)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{""}
+	for _, v := range valves {
+		header = append(header, v.name)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, from := range valves {
+		row := []string{from.name}
+		for _, to := range valves {
+			cell := ""
+			if d, reachable := distMx[from][to]; reachable {
+				cell = strconv.Itoa(d)
+			}
+			row = append(row, cell)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
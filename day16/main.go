@@ -3,12 +3,12 @@ package main
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/samber/lo"
 	"kfet.org/aoc_common/assert"
-	"kfet.org/aoc_common/calc"
 	"kfet.org/aoc_common/input"
 )
 
@@ -27,9 +27,15 @@ func NewValve(name string, rate int) *valve {
 	}
 }
 
+func (v *valve) sortedTunnels() []*valve {
+	res := lo.Keys(v.tunnels)
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
 func (v *valve) String() string {
 	var sb strings.Builder
-	ts := lo.Map(lo.Keys(v.tunnels), func(item *valve, index int) string {
+	ts := lo.Map(v.sortedTunnels(), func(item *valve, index int) string {
 		return item.name
 	})
 	sb.WriteString(fmt.Sprintf("name: %s, rate: %d, tunnels: %s\n", v.name, v.rate, strings.Join(ts, ", ")))
@@ -50,12 +56,21 @@ func NewMesh(fileName string) (mesh, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = m.validate(); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
+func (m *mesh) sortedValves() []*valve {
+	res := lo.Values(*m)
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
 func (m *mesh) String() string {
 	var sb strings.Builder
-	for _, v := range *m {
+	for _, v := range m.sortedValves() {
 		sb.WriteString(v.String())
 	}
 	return sb.String()
@@ -133,26 +148,6 @@ func (m *mesh) buildDistanceLimitMatrix() valveToValveMatrix {
 	return vvm
 }
 
-func unvisitedUpperRateLimit(actors []actor, distMx valveToValveMatrix, unvisited map[*valve]struct{}) int {
-	var maxLim int
-	for cv := range unvisited {
-		// find max rate for this node
-		var maxRate int
-		for _, ac := range actors {
-			cvTimeLeft := ac.timeLeft - ac.timeToOpenValve(cv, distMx)
-			if cvTimeLeft <= 0 {
-				continue
-			}
-			acRate := cv.rate * cvTimeLeft
-			if acRate > maxRate {
-				maxRate = acRate
-			}
-		}
-		maxLim += maxRate
-	}
-	return maxLim
-}
-
 type actor struct {
 	v        *valve
 	timeLeft int
@@ -163,67 +158,15 @@ func (a *actor) timeToOpenValve(v *valve, distMx valveToValveMatrix) int {
 	return distMx[a.v][v] + 1
 }
 
-func maxFlow(actors []actor, distMx valveToValveMatrix, unopen map[*valve]struct{}, requiredFlowRate int) int {
-	// enum order of opening, and calculate distance
-	var maxFlowRate int
-	for nextValve := range unopen {
-		var maxNvTimeLeft int
-		maxTimeActorIndexes := []int{}
-		// pick the actor which can get to the valve the soonest
-		for i, ac := range actors {
-			nvTimeLeft := ac.timeLeft - ac.timeToOpenValve(nextValve, distMx)
-			if nvTimeLeft > maxNvTimeLeft {
-				maxNvTimeLeft = nvTimeLeft
-				maxTimeActorIndexes = []int{i}
-			} else if nvTimeLeft == maxNvTimeLeft {
-				maxTimeActorIndexes = append(maxTimeActorIndexes, i)
-			}
-		}
-
-		if maxNvTimeLeft <= 0 {
-			continue
-		}
-
-		for _, i := range maxTimeActorIndexes {
-			// for each actor which can get at the earliest time to the valve
-			actorsCopy := lo.Map(actors, func(item actor, index int) actor { return item })
-
-			// move selected actor to next valve
-			actorsCopy[i].timeLeft -= actorsCopy[i].timeToOpenValve(nextValve, distMx)
-			actorsCopy[i].v = nextValve
-
-			// consider the next valve open
-			nextValveFlowRate := actorsCopy[i].timeLeft * nextValve.rate
-
-			nextUnopen := input.CopyMap(unopen, func(item *valve, s struct{}) bool { return item != nextValve })
-			upperLim := unvisitedUpperRateLimit(actorsCopy, distMx, nextUnopen)
-			if nextValveFlowRate+upperLim <= maxFlowRate {
-				continue
-			}
-			if nextValveFlowRate+upperLim <= requiredFlowRate {
-				continue
-			}
-
-			nextValveFlowRate += maxFlow(actorsCopy, distMx, nextUnopen,
-				// pass required minimum flow rate to the recursive call
-				calc.Max(maxFlowRate-nextValveFlowRate, requiredFlowRate-nextValveFlowRate))
-			if nextValveFlowRate > maxFlowRate {
-				maxFlowRate = nextValveFlowRate
-			}
-		}
-	}
-
-	return maxFlowRate
+// opening is a single decision of the search: the actor opens the valve with
+// timeLeft minutes remaining after it is open.
+type opening struct {
+	actor    int
+	valve    *valve
+	timeLeft int
 }
 
-func (m *mesh) maxFlow(actorNames []string, timeLeft int, distMx valveToValveMatrix) int {
-	allChildren := lo.MapEntries(*m, func(key string, value *valve) (*valve, struct{}) {
-		return value, struct{}{}
-	})
-	allChildren = input.CopyMap(allChildren, func(v *valve, s struct{}) bool {
-		return v.rate > 0
-	})
-
+func (m *mesh) maxFlow(actorNames []string, timeLeft int, distMx valveToValveMatrix) (*plan, error) {
 	actors := lo.Map(actorNames, func(name string, index int) actor {
 		return actor{
 			name:     name,
@@ -232,19 +175,30 @@ func (m *mesh) maxFlow(actorNames []string, timeLeft int, distMx valveToValveMat
 		}
 	})
 
-	return maxFlow(actors, distMx, allChildren, 0)
+	s, err := NewSolver(*m, distMx)
+	if err != nil {
+		return nil, err
+	}
+	openings := s.solve(actors)
+	return m.buildPlan(actors, timeLeft, openings), nil
 }
 
-func processFile(fileName string, actorNames []string, timeLeft int) (int, error) {
+func findPlan(fileName string, actorNames []string, timeLeft int) (*plan, error) {
 	m, err := NewMesh(fileName)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	matrix := m.buildDistanceLimitMatrix()
-	maxFlow := m.maxFlow(actorNames, timeLeft, matrix)
+	return m.maxFlow(actorNames, timeLeft, matrix)
+}
 
-	return maxFlow, nil
+func processFile(fileName string, actorNames []string, timeLeft int) (int, error) {
+	p, err := findPlan(fileName, actorNames, timeLeft)
+	if err != nil {
+		return 0, err
+	}
+	return p.pressure, nil
 }
 
 func main() {
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan(t *testing.T) {
+	p, err := findPlan("data/part_one.txt", []string{"me"}, 30)
+	assert.Nil(t, err)
+	assert.Equal(t, 1651, p.pressure)
+
+	steps := p.actors[0].steps
+	names := []string{}
+	var pressure, minute int
+	for _, st := range steps {
+		names = append(names, st.valve.name)
+		pressure += st.pressure
+		assert.Equal(t, st.valve, st.path[len(st.path)-1])
+		assert.Equal(t, minute+len(st.path)+1, st.minute)
+		minute = st.minute
+	}
+	assert.Equal(t, []string{"DD", "BB", "JJ", "HH", "EE", "CC"}, names)
+	assert.Equal(t, p.pressure, pressure)
+}
+
+func TestTimeline(t *testing.T) {
+	p, err := findPlan("data/part_one.txt", []string{"me", "elephant"}, 26)
+	assert.Nil(t, err)
+	assert.Equal(t, 1707, p.pressure)
+
+	// the actors are interchangeable, either one may take either route
+	routes := []string{}
+	for _, ap := range p.actors {
+		names := []string{}
+		for _, st := range ap.steps {
+			names = append(names, st.valve.name)
+		}
+		routes = append(routes, strings.Join(names, ","))
+	}
+	assert.ElementsMatch(t, []string{"DD,HH,EE", "JJ,BB,CC"}, routes)
+
+	timeline := p.timeline()
+	assert.Equal(t, 26, strings.Count(timeline, "== Minute"))
+	assert.True(t, strings.HasPrefix(timeline, `== Minute 1 ==
+No valves are open.
+`), timeline)
+	assert.Contains(t, timeline, `== Minute 3 ==
+Valve DD is open, releasing 20 pressure.
+`)
+	assert.Contains(t, timeline, "You open valve")
+	assert.Contains(t, timeline, "The elephant opens valve")
+	assert.True(t, strings.HasSuffix(timeline, `== Minute 26 ==
+Valves BB, CC, DD, EE, HH, and JJ are open, releasing 81 pressure.
+
+`), timeline)
+}
+
+func TestActors(t *testing.T) {
+	tests := []struct {
+		fileName string
+		actors   []string
+		timeLeft int
+		pressure int
+	}{
+		{"data/input.txt", []string{"me"}, 30, 1659},
+		{"data/input.txt", []string{"me", "elephant"}, 26, 2382},
+		{"data/part_one.txt", []string{"me", "elephant", "llama"}, 26, 1794},
+		{"data/part_one.txt", []string{"me", "elephant", "llama", "yak"}, 26, 1825},
+	}
+	for _, tc := range tests {
+		p, err := findPlan(tc.fileName, tc.actors, tc.timeLeft)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.pressure, p.pressure, "%s %v", tc.fileName, tc.actors)
+
+		// every valve is opened at most once, by one of the actors
+		opened := map[*valve]struct{}{}
+		for _, ap := range p.actors {
+			for _, st := range ap.steps {
+				_, twice := opened[st.valve]
+				assert.False(t, twice)
+				opened[st.valve] = struct{}{}
+				assert.True(t, st.minute < tc.timeLeft)
+			}
+		}
+	}
+}
+
+func TestExports(t *testing.T) {
+	m, err := NewMesh("data/part_one.txt")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(m.String(),
+		"name: AA, rate: 0, tunnels: BB, DD, II\nname: BB, rate: 13, tunnels: AA, CC\n"))
+
+	var dot strings.Builder
+	assert.Nil(t, m.writeDOT(&dot))
+	assert.True(t, strings.HasPrefix(dot.String(), "digraph valves {\n  AA [label=\"AA\\nrate=0\"];\n"))
+	assert.Contains(t, dot.String(), "  AA -> BB [dir=both];\n")
+	assert.NotContains(t, dot.String(), "BB -> AA")
+
+	var csv strings.Builder
+	assert.Nil(t, m.writeDistanceCSV(&csv, m.buildDistanceLimitMatrix()))
+	lines := strings.Split(csv.String(), "\n")
+	assert.Equal(t, ",AA,BB,CC,DD,EE,HH,JJ", lines[0])
+	assert.Equal(t, "AA,0,1,2,1,2,5,2", lines[1])
+	assert.Equal(t, 9, len(lines))
+}
+
+func TestValidate(t *testing.T) {
+	fileName := t.TempDir() + "/mesh.txt"
+	err := os.WriteFile(fileName, []byte(
+		"Valve AA has flow rate=0; tunnels lead to valves BB, ZZ\n"+
+			"Valve BB has flow rate=3; tunnels lead to valves AA, ZZ, YY\n"), 0644)
+	assert.Nil(t, err)
+
+	_, err = NewMesh(fileName)
+	assert.EqualError(t, err, "valve YY not defined, referenced by BB\n"+
+		"valve ZZ not defined, referenced by AA, BB")
+}
+
+func TestSolverLimit(t *testing.T) {
+	m := mesh{}
+	for i := 0; i <= maxSolverValves; i++ {
+		name := fmt.Sprintf("V%d", i)
+		m[name] = NewValve(name, 1)
+	}
+	_, err := NewSolver(m, nil)
+	assert.NotNil(t, err)
+
+	delete(m, "V0")
+	_, err = NewSolver(m, nil)
+	assert.Nil(t, err)
+}
+
+// gridMesh returns w by h valves with a positive flow rate, neighbours two
+// tunnels apart, and AA next to the middle one.
+func gridMesh(w, h int) mesh {
+	m := mesh{}
+	link := func(a, b *valve) {
+		a.tunnels[b] = struct{}{}
+		b.tunnels[a] = struct{}{}
+	}
+	corridor := func(name string, a, b *valve) {
+		c := NewValve(name, 0)
+		m[name] = c
+		link(a, c)
+		link(c, b)
+	}
+	name := func(x, y int) string { return fmt.Sprintf("V%d_%d", x, y) }
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m[name(x, y)] = NewValve(name(x, y), (x*7+y*13)%23+1)
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x+1 < w {
+				corridor("H"+name(x, y), m[name(x, y)], m[name(x+1, y)])
+			}
+			if y+1 < h {
+				corridor("W"+name(x, y), m[name(x, y)], m[name(x, y+1)])
+			}
+		}
+	}
+	m["AA"] = NewValve("AA", 0)
+	link(m["AA"], m[name(w/2, h/2)])
+	return m
+}
+
+func TestSolverTime(t *testing.T) {
+	const budget = time.Second
+	actors := []string{"me", "elephant", "llama", "yak"}
+
+	for _, tc := range []struct {
+		actors   int
+		pressure int
+	}{
+		{3, 3315},
+		{4, 3858},
+	} {
+		start := time.Now()
+		p, err := findPlan("data/input.txt", actors[:tc.actors], 26)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.pressure, p.pressure)
+		assert.Less(t, time.Since(start), budget, "%d actors", tc.actors)
+	}
+
+	// more valves than the solver used to take
+	m := gridMesh(4, 5)
+	for _, tc := range []struct {
+		actors   int
+		pressure int
+	}{
+		{1, 1626},
+		{2, 2789},
+		{3, 3476},
+	} {
+		start := time.Now()
+		p, err := m.maxFlow(actors[:tc.actors], 26, m.buildDistanceLimitMatrix())
+		assert.Nil(t, err)
+		assert.Equal(t, tc.pressure, p.pressure)
+		assert.Less(t, time.Since(start), budget, "%d actors on the grid", tc.actors)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type planStep struct {
+	// valves walked through, ending at the opened valve
+	path  []*valve
+	valve *valve
+	// the minute during which the valve gets opened
+	minute   int
+	pressure int
+}
+
+type actorPlan struct {
+	name  string
+	start *valve
+	steps []*planStep
+}
+
+type plan struct {
+	timeLimit int
+	pressure  int
+	actors    []*actorPlan
+}
+
+// shortestPath returns the valves on the way from one valve to the other,
+// excluding 'from'. Ties are broken by valve name.
+func (m *mesh) shortestPath(from, to *valve) []*valve {
+	parent := map[*valve]*valve{from: nil}
+	wave := []*valve{from}
+	for len(wave) > 0 {
+		if _, found := parent[to]; found {
+			break
+		}
+		nextWave := []*valve{}
+		for _, v := range wave {
+			for _, t := range v.sortedTunnels() {
+				if _, visited := parent[t]; visited {
+					continue
+				}
+				parent[t] = v
+				nextWave = append(nextWave, t)
+			}
+		}
+		wave = nextWave
+	}
+
+	path := []*valve{}
+	for v := to; v != from; v = parent[v] {
+		path = append([]*valve{v}, path...)
+	}
+	return path
+}
+
+func (m *mesh) buildPlan(actors []actor, timeLimit int, openings []opening) *plan {
+	p := &plan{timeLimit: timeLimit}
+	pos := make([]*valve, len(actors))
+	for i, ac := range actors {
+		p.actors = append(p.actors, &actorPlan{name: ac.name, start: ac.v})
+		pos[i] = ac.v
+	}
+
+	for _, o := range openings {
+		st := &planStep{
+			path:     m.shortestPath(pos[o.actor], o.valve),
+			valve:    o.valve,
+			minute:   timeLimit - o.timeLeft,
+			pressure: o.valve.rate * o.timeLeft,
+		}
+		ap := p.actors[o.actor]
+		ap.steps = append(ap.steps, st)
+		pos[o.actor] = o.valve
+		p.pressure += st.pressure
+	}
+	return p
+}
+
+// action describes what the actor does during the given minute, or "" when
+// it is done.
+func (ap *actorPlan) action(minute int) string {
+	subject, move, open := "The "+ap.name, "moves", "opens"
+	if ap.name == "me" {
+		subject, move, open = "You", "move", "open"
+	}
+
+	start := 1
+	for _, st := range ap.steps {
+		if minute == st.minute {
+			return fmt.Sprintf("%s %s valve %s.", subject, open, st.valve.name)
+		}
+		if minute < st.minute {
+			return fmt.Sprintf("%s %s to valve %s.", subject, move, st.path[minute-start].name)
+		}
+		start = st.minute + 1
+	}
+	return ""
+}
+
+func openValvesString(open []*valve) string {
+	if len(open) == 0 {
+		return "No valves are open."
+	}
+
+	var rate int
+	names := make([]string, len(open))
+	for i, v := range open {
+		names[i] = v.name
+		rate += v.rate
+	}
+	sort.Strings(names)
+
+	switch len(names) {
+	case 1:
+		return fmt.Sprintf("Valve %s is open, releasing %d pressure.", names[0], rate)
+	case 2:
+		return fmt.Sprintf("Valves %s and %s are open, releasing %d pressure.", names[0], names[1], rate)
+	}
+	return fmt.Sprintf("Valves %s, and %s are open, releasing %d pressure.",
+		strings.Join(names[:len(names)-1], ", "), names[len(names)-1], rate)
+}
+
+// timeline renders the plan as the minute by minute narrative of the puzzle.
+func (p *plan) timeline() string {
+	var sb strings.Builder
+	open := []*valve{}
+	for minute := 1; minute <= p.timeLimit; minute++ {
+		sb.WriteString(fmt.Sprintf("== Minute %d ==\n", minute))
+		sb.WriteString(openValvesString(open))
+		sb.WriteRune('\n')
+		for _, ap := range p.actors {
+			if a := ap.action(minute); a != "" {
+				sb.WriteString(a)
+				sb.WriteRune('\n')
+			}
+		}
+		sb.WriteRune('\n')
+
+		for _, ap := range p.actors {
+			for _, st := range ap.steps {
+				if st.minute == minute {
+					open = append(open, st.valve)
+				}
+			}
+		}
+	}
+	return sb.String()
+}
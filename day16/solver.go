@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+
+	"kfet.org/aoc_common/calc"
+)
+
+// valveSet is a bitmask over the valves with a positive flow rate.
+type valveSet uint64
+
+// maxSolverValves is the most valves with a positive flow rate a valveSet
+// holds.
+const maxSolverValves = 64
+
+// route is a single actor's order of opening valves.
+type route struct {
+	pressure int
+	open     valveSet
+	valves   []int
+}
+
+// solver finds the best split of the valves between any number of actors.
+// Each actor's best route is computed per set of opened valves, then the sets
+// are split between the actors.
+type solver struct {
+	valves []*valve
+	distMx valveToValveMatrix
+}
+
+func NewSolver(m mesh, distMx valveToValveMatrix) (*solver, error) {
+	s := &solver{distMx: distMx}
+	for _, v := range m {
+		if v.rate > 0 {
+			s.valves = append(s.valves, v)
+		}
+	}
+	if len(s.valves) > maxSolverValves {
+		return nil, errors.New("too many valves with a positive flow rate")
+	}
+	sort.Slice(s.valves, func(i, j int) bool { return s.valves[i].name < s.valves[j].name })
+	return s, nil
+}
+
+// state is where a route stands: at the valve it just opened, with the time
+// left and the valves opened so far. Routes reaching the same state only
+// differ in the pressure released so far, so only the best one is kept.
+type state struct {
+	v        int // index of the valve, len(s.valves) for the start
+	timeLeft int
+	open     valveSet
+}
+
+type stateNode struct {
+	state
+	pressure int
+	parent   int // index of the node before, -1 for the start
+}
+
+// distances returns the distances between the valves by index, the start
+// being the last one, -1 where there is no way.
+func (s *solver) distances(start *valve) [][]int {
+	from := append(append([]*valve(nil), s.valves...), start)
+	res := make([][]int, len(from))
+	for i, f := range from {
+		res[i] = make([]int, len(s.valves))
+		for j, v := range s.valves {
+			dist, reachable := s.distMx[f][v]
+			if !reachable {
+				dist = -1
+			}
+			res[i][j] = dist
+		}
+	}
+	return res
+}
+
+// bestRoutes returns the best route for every set of valves some route opens,
+// highest pressure first, leaving out the routes beaten by one opening fewer
+// valves. The empty route is always there.
+func (s *solver) bestRoutes(start *valve, timeLeft int) []route {
+	dists := s.distances(start)
+	nodes := []stateNode{{
+		state:  state{len(s.valves), timeLeft, 0},
+		parent: -1,
+	}}
+	index := map[state]int{nodes[0].state: 0}
+	// nodes by time left, a node is only reached from nodes with more time
+	// left, so it is final by the time its bucket is expanded
+	buckets := make([][]int, timeLeft+1)
+	buckets[timeLeft] = []int{0}
+	// front holds the best pressure of the nodes expanded so far per valve
+	// and open valves. They all had as much time left or more, so a node not
+	// beating it can do nothing they could not.
+	type frontKey struct {
+		v    int
+		open valveSet
+	}
+	front := map[frontKey]int{}
+
+	for t := timeLeft; t > 0; t-- {
+		for _, n := range buckets[t] {
+			from := nodes[n]
+			fk := frontKey{from.v, from.open}
+			if p, ok := front[fk]; ok && p >= from.pressure {
+				continue
+			}
+			front[fk] = from.pressure
+			for i, dist := range dists[from.v] {
+				if from.open&(1<<i) != 0 || dist < 0 || dist+1 >= t {
+					continue
+				}
+				nextTimeLeft := t - dist - 1
+				next := state{i, nextTimeLeft, from.open | 1<<i}
+				pressure := from.pressure + s.valves[i].rate*nextTimeLeft
+
+				if m, ok := index[next]; ok {
+					if pressure > nodes[m].pressure {
+						nodes[m].pressure = pressure
+						nodes[m].parent = n
+					}
+					continue
+				}
+				index[next] = len(nodes)
+				buckets[nextTimeLeft] = append(buckets[nextTimeLeft], len(nodes))
+				nodes = append(nodes, stateNode{
+					state:    next,
+					pressure: pressure,
+					parent:   n,
+				})
+			}
+		}
+	}
+
+	// the best node per set of open valves, the first found on ties
+	bestNode := map[valveSet]int{}
+	var sets []valveSet
+	for n := range nodes {
+		b, ok := bestNode[nodes[n].open]
+		if !ok {
+			sets = append(sets, nodes[n].open)
+		}
+		if !ok || nodes[n].pressure > nodes[b].pressure {
+			bestNode[nodes[n].open] = n
+		}
+	}
+
+	// a route is only worth it if it beats all the routes opening fewer
+	// valves. Any subset of an open set is open by some route as well, the
+	// one skipping the valves left out, so the best of the subsets builds up
+	// one valve at a time.
+	sort.Slice(sets, func(i, j int) bool {
+		ci, cj := bits.OnesCount64(uint64(sets[i])), bits.OnesCount64(uint64(sets[j]))
+		if ci != cj {
+			return ci < cj
+		}
+		return sets[i] < sets[j]
+	})
+	sup := make(map[valveSet]int, len(sets))
+	var kept []valveSet
+	for _, set := range sets {
+		p := nodes[bestNode[set]].pressure
+		var subs int
+		for rest := set; rest != 0; rest &= rest - 1 {
+			subs = calc.Max(subs, sup[set&^(rest&-rest)])
+		}
+		sup[set] = calc.Max(p, subs)
+		if set == 0 || p > subs {
+			kept = append(kept, set)
+		}
+	}
+
+	res := make([]route, len(kept))
+	for i, set := range kept {
+		n := bestNode[set]
+		r := route{pressure: nodes[n].pressure, open: set}
+		for ; nodes[n].parent >= 0; n = nodes[n].parent {
+			r.valves = append(r.valves, nodes[n].v)
+		}
+		for a, b := 0, len(r.valves)-1; a < b; a, b = a+1, b-1 {
+			r.valves[a], r.valves[b] = r.valves[b], r.valves[a]
+		}
+		res[i] = r
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].pressure > res[j].pressure })
+	return res
+}
+
+// split picks a route per actor, opening disjoint sets of valves, with the
+// highest total pressure. The routes are tried best first, and a branch is
+// cut as soon as even the best routes of the actors left cannot beat the
+// best split found.
+type split struct {
+	routes [][]route
+	// rates are the flow rates of the valves, highest first, byRate their
+	// valve indexes
+	rates, byRate []int
+	// slots[j] is the most time left any actor can have after opening its
+	// j-th valve
+	slots []int
+	// same[k] counts the actors right after k with the same routes
+	same []int
+	// rest[k] is the sum of the best pressures of actors k and after
+	rest []int
+	pick []int
+
+	best     int
+	bestPick []int
+}
+
+func newSplit(routes [][]route, byRate, rates, slots []int) *split {
+	sp := &split{
+		routes:   routes,
+		rates:    rates,
+		byRate:   byRate,
+		slots:    slots,
+		same:     make([]int, len(routes)),
+		rest:     make([]int, len(routes)+1),
+		pick:     make([]int, len(routes)),
+		best:     -1,
+		bestPick: make([]int, len(routes)),
+	}
+	for k := len(routes) - 1; k >= 0; k-- {
+		sp.rest[k] = sp.rest[k+1] + routes[k][0].pressure
+		if k+1 < len(routes) && sp.sameRoutes(k+1) {
+			sp.same[k] = sp.same[k+1] + 1
+		}
+	}
+	return sp
+}
+
+func (sp *split) sameRoutes(k int) bool {
+	return &sp.routes[k][0] == &sp.routes[k-1][0]
+}
+
+// bound is an upper bound of the pressure the actors release opening valves
+// of the set: each of them opens one valve per slot, the highest rates in
+// the earliest slots.
+func (sp *split) bound(set valveSet, actors int) int {
+	var res, opened int
+	for j, i := range sp.byRate {
+		if set&(1<<i) == 0 {
+			continue
+		}
+		slot := opened / actors
+		if slot >= len(sp.slots) {
+			break
+		}
+		res += sp.rates[j] * sp.slots[slot]
+		opened++
+	}
+	return res
+}
+
+func (sp *split) search(k int, used valveSet, pressure int) {
+	if k == len(sp.routes) {
+		if pressure > sp.best {
+			sp.best = pressure
+			copy(sp.bestPick, sp.pick)
+		}
+		return
+	}
+	from := 0
+	if k > 0 && sp.sameRoutes(k) {
+		// actors with the same routes are interchangeable, only try their
+		// picks in one order
+		from = sp.pick[k-1]
+	}
+	if pressure+sp.bound(^used, len(sp.routes)-k) <= sp.best {
+		return
+	}
+	same := sp.same[k]
+	for i := from; i < len(sp.routes[k]); i++ {
+		r := &sp.routes[k][i]
+		// the actors with the same routes pick after this one, so they get
+		// no more than this one
+		if pressure+r.pressure*(1+same)+sp.rest[k+1+same] <= sp.best {
+			break
+		}
+		if r.open&used != 0 {
+			continue
+		}
+		sp.pick[k] = i
+		sp.search(k+1, used|r.open, pressure+r.pressure)
+	}
+}
+
+// slots returns the most time left any of the actors can have after opening
+// its first, second, ... valve: walking the shortest tunnels from the start,
+// then between the valves.
+func (s *solver) slots(actors []actor) []int {
+	step := math.MaxInt
+	for i, v := range s.valves {
+		for j, w := range s.valves {
+			if dist, ok := s.distMx[v][w]; ok && i != j {
+				step = calc.Min(step, dist+1)
+			}
+		}
+	}
+	var res []int
+	for _, ac := range actors {
+		first := math.MaxInt
+		for _, v := range s.valves {
+			if dist, ok := s.distMx[ac.v][v]; ok {
+				first = calc.Min(first, dist+1)
+			}
+		}
+		if first == math.MaxInt {
+			continue
+		}
+		for j, t := 0, ac.timeLeft-first; t > 0; j, t = j+1, t-step {
+			if j == len(res) {
+				res = append(res, 0)
+			}
+			res[j] = calc.Max(res[j], t)
+			if step == math.MaxInt {
+				break
+			}
+		}
+	}
+	return res
+}
+
+// solve returns the valve openings of the best plan for the actors.
+func (s *solver) solve(actors []actor) []opening {
+	type routeKey struct {
+		start    *valve
+		timeLeft int
+	}
+	tables := map[routeKey][]route{}
+	routes := make([][]route, len(actors))
+	for i, ac := range actors {
+		k := routeKey{ac.v, ac.timeLeft}
+		if _, ok := tables[k]; !ok {
+			tables[k] = s.bestRoutes(ac.v, ac.timeLeft)
+		}
+		routes[i] = tables[k]
+	}
+
+	byRate := make([]int, len(s.valves))
+	for i := range byRate {
+		byRate[i] = i
+	}
+	sort.SliceStable(byRate, func(i, j int) bool { return s.valves[byRate[i]].rate > s.valves[byRate[j]].rate })
+	rates := make([]int, len(s.valves))
+	for j, i := range byRate {
+		rates[j] = s.valves[i].rate
+	}
+	sp := newSplit(routes, byRate, rates, s.slots(actors))
+	sp.search(0, 0, 0)
+
+	res := []opening{}
+	for k, ac := range actors {
+		r := routes[k][sp.bestPick[k]]
+		for _, i := range r.valves {
+			v := s.valves[i]
+			ac.timeLeft -= ac.timeToOpenValve(v, s.distMx)
+			ac.v = v
+			res = append(res, opening{
+				actor:    k,
+				valve:    v,
+				timeLeft: ac.timeLeft,
+			})
+		}
+	}
+	return res
+}
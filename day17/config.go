@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"kfet.org/aoc_common/input"
+)
+
+const spritesFile string = "data/rocks.txt"
+
+type worldConfig struct {
+	width int
+	// a new rock appears with its left edge spawnX cells away from the left
+	// wall, and its bottom edge spawnGap empty rows above the highest rock
+	spawnX   int
+	spawnGap int
+
+	sprites []*mask
+	jets    []move
+}
+
+// NewWorldConfig returns the puzzle's chamber with the given rocks and jets.
+func NewWorldConfig(sprites []*mask, jets []move) *worldConfig {
+	return &worldConfig{
+		width:    7,
+		spawnX:   2,
+		spawnGap: 3,
+		sprites:  sprites,
+		jets:     jets,
+	}
+}
+
+func readWorldConfig(spritesFile, jetsFile string) (*worldConfig, error) {
+	open := func(name string, read func(io.Reader) error) error {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return read(file)
+	}
+
+	var (
+		sprites []*mask
+		jets    []move
+	)
+	err := open(spritesFile, func(r io.Reader) (err error) {
+		sprites, err = readSprites(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = open(jetsFile, func(r io.Reader) (err error) {
+		jets, err = readJets(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewWorldConfig(sprites, jets), nil
+}
+
+func (cfg *worldConfig) validate() error {
+	if cfg.width <= 0 || cfg.width > maxChamberWidth {
+		return fmt.Errorf("wrong chamber width %d", cfg.width)
+	}
+	if cfg.spawnX < 0 || cfg.spawnGap < 0 {
+		return fmt.Errorf("wrong spawn offsets x: %d, gap: %d", cfg.spawnX, cfg.spawnGap)
+	}
+	if len(cfg.sprites) == 0 {
+		return errors.New("no rock sprites")
+	}
+	if len(cfg.jets) == 0 {
+		return errors.New("no jets")
+	}
+	for i, s := range cfg.sprites {
+		if len(*s) == 0 {
+			return fmt.Errorf("rock sprite %d is empty", i)
+		}
+		for _, row := range *s {
+			if cfg.spawnX+len(row) > cfg.width {
+				return fmt.Errorf("rock sprite %d does not fit in the chamber", i)
+			}
+		}
+	}
+	return nil
+}
+
+// readSprites reads the rock shapes drawn with '.' and '#', separated by
+// empty lines.
+func readSprites(r io.Reader) ([]*mask, error) {
+	runeMap := map[rune]uint8{
+		'.': 0,
+		'#': 1,
+	}
+
+	sprites := []*mask{}
+	sprite := &mask{}
+	err := input.ReadLines(r, func(line string) error {
+		if len(line) == 0 {
+			if len(*sprite) > 0 {
+				sprites = append(sprites, sprite)
+				sprite = &mask{}
+			}
+			return nil
+		}
+
+		row := make([]uint8, 0, len(line))
+		for _, r := range line {
+			bit, ok := runeMap[r]
+			if !ok {
+				return fmt.Errorf("wrong rock sprite character %q", r)
+			}
+			row = append(row, bit)
+		}
+		(*sprite) = append((*sprite), row)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// append the last rock
+	if len(*sprite) > 0 {
+		sprites = append(sprites, sprite)
+	}
+	return sprites, nil
+}
+
+// readJets reads the jet pattern, which may be split over several lines.
+func readJets(r io.Reader) ([]move, error) {
+	moveMap := map[rune]move{
+		'<': {-1, 0},
+		'>': {+1, 0},
+	}
+
+	jets := []move{}
+	err := input.ReadLines(r, func(line string) error {
+		for _, r := range line {
+			mv, ok := moveMap[r]
+			if !ok {
+				return fmt.Errorf("wrong jet character %q", r)
+			}
+			jets = append(jets, mv)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jets, nil
+}
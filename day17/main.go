@@ -1,73 +1,129 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
-
-	"github.com/samber/lo"
-	"kfet.org/aoc_common/input"
 )
 
 type mask [][]uint8
 
+// maxChamberWidth is the widest chamber a row bitmask can hold.
+const maxChamberWidth = 64
+
+// trimHeight is how tall the chamber grows before looking for rows to drop.
+const trimHeight = 64
+
+// rockMasks are the rows of a sprite as bitmasks, bottom row first, shifted
+// for every x offset in the chamber. Bit x stands for column x.
+type rockMasks struct {
+	width   int
+	height  int
+	shifted []uint64
+}
+
+func (rm *rockMasks) at(x int) []uint64 {
+	return rm.shifted[x*rm.height : (x+1)*rm.height]
+}
+
+func newRockMasks(m *mask, chamberWidth int) *rockMasks {
+	rm := &rockMasks{height: len(*m)}
+	bits := make([]uint64, rm.height)
+	for ry, rr := range *m {
+		if len(rr) > rm.width {
+			rm.width = len(rr)
+		}
+		for rx, bit := range rr {
+			bits[rm.height-1-ry] |= uint64(bit&1) << rx
+		}
+	}
+
+	for x := 0; x+rm.width <= chamberWidth; x++ {
+		for _, b := range bits {
+			rm.shifted = append(rm.shifted, b<<x)
+		}
+	}
+	return rm
+}
+
 type rock struct {
 	m        *mask
+	masks    *rockMasks
 	x, y     int
 	moveType int
 }
 
 func NewRock(m *mask, x, y int) *rock {
+	return newRockWithMasks(m, newRockMasks(m, maxChamberWidth), x, y)
+}
+
+func newRockWithMasks(m *mask, masks *rockMasks, x, y int) *rock {
 	r := &rock{
-		m: m,
-		x: x,
-		y: y,
+		m:     m,
+		masks: masks,
+		x:     x,
+		y:     y,
 	}
 	return r
 }
 
-const chamberWidth int = 7
-
 type chamber struct {
 	w         int
 	h         int
+	full      uint64
 	maskStart *big.Int
-	rows      mask
+	rows      []uint64
 }
 
 func NewChamber(width int) *chamber {
 	return &chamber{
 		w:         width,
+		full:      ^uint64(0) >> (maxChamberWidth - width),
 		maskStart: big.NewInt(0),
 	}
 }
 
 func (ch *chamber) String() string {
-	bitMap := map[uint8]string{
-		0: ".",
-		1: "#",
-	}
 	var sb strings.Builder
 	for i := len(ch.rows) - 1; i >= 0; i-- {
-		for _, c := range ch.rows[i] {
-			sb.WriteString(bitMap[c])
+		for x := 0; x < ch.w; x++ {
+			if ch.rows[i]&(1<<x) != 0 {
+				sb.WriteString("#")
+			} else {
+				sb.WriteString(".")
+			}
 		}
 		sb.WriteString("\n")
 	}
 	return sb.String()
 }
 
-func (ch *chamber) isNewFloor(row int) bool {
-	for _, c := range ch.rows[row] {
-		if c == 0 {
-			return false
+// lowestReachable returns the lowest row a falling rock can still get to. A
+// rock only moves left, right and down, so the free cells are flooded from
+// the top one row at a time.
+func (ch *chamber) lowestReachable() int {
+	reach := ch.full
+	for y := ch.h - 1; y >= 0; y-- {
+		free := ^ch.rows[y] & ch.full
+		reach &= free
+		for {
+			next := (reach | reach<<1 | reach>>1) & free
+			if next == reach {
+				break
+			}
+			reach = next
+		}
+		if reach == 0 {
+			return y + 1
 		}
 	}
-	return true
+	return 0
 }
 
 func (ch *chamber) newFloor(row int) {
-	ch.rows = ch.rows[row:]
+	// move the rows down in place, so the buffer does not need to grow again
+	ch.rows = ch.rows[:copy(ch.rows, ch.rows[row:])]
 	ch.h = len(ch.rows)
 	ch.maskStart.Add(ch.maskStart, big.NewInt(int64(row)))
 }
@@ -75,61 +131,74 @@ func (ch *chamber) newFloor(row int) {
 func (ch *chamber) stampRock(r *rock) {
 	// add rows as needed
 	for ch.h < r.y+1 {
-		ch.rows = append(ch.rows, make([]uint8, ch.w))
+		ch.rows = append(ch.rows, 0)
 		ch.h++
 	}
 
 	// stamp the rock mask
-	var newFloorRow int
-	for ry, rr := range *r.m {
-		cy := r.y - ry
-		for rx, bit := range rr {
-			cx := rx + r.x
-			if cx < 0 || cx >= ch.w {
-				continue
-			}
-			ch.rows[cy][cx] |= bit
-		}
-
-		if ch.isNewFloor(cy) {
-			newFloorRow = cy
-		}
+	bottom := r.y - r.masks.height + 1
+	for ry, bits := range r.masks.at(r.x) {
+		ch.rows[bottom+ry] |= bits & ch.full
 	}
 
-	if newFloorRow > 0 {
-		ch.newFloor(newFloorRow)
+	if ch.h < trimHeight {
+		return
+	}
+	// rows below the one blocking the lowest reachable cells can never be hit
+	// again
+	if floor := ch.lowestReachable() - 1; floor > 0 {
+		ch.newFloor(floor)
 	}
 }
 
 func (c *chamber) testMove(r *rock, mv move) bool {
-	testX := r.x + mv.dx
-	testY := r.y + mv.dy
-	for ry, rr := range *r.m {
-		cy := testY - ry
-		if cy < 0 {
-			// hit the floor
-			return false
+	return c.fits(r.masks, r.x+mv.dx, r.y+mv.dy)
+}
+
+// fits tells if the rock can be at x, y with its top row at y.
+func (c *chamber) fits(masks *rockMasks, x, y int) bool {
+	if x < 0 || x+masks.width > c.w {
+		// attempt to move outside of chamber
+		return false
+	}
+	bottom := y - masks.height + 1
+	if bottom < 0 {
+		// hit the floor
+		return false
+	}
+	for ry, bits := range masks.at(x) {
+		cy := bottom + ry
+		if cy >= c.h {
+			// above chamber top
+			break
 		}
-		for rx, bit := range rr {
-			cx := rx + testX
-			if cx < 0 || cx >= c.w {
-				// attempt to move outside of chamber
-				return false
-			}
-			if cy >= c.h {
-				// above chamber top
-				continue
-			}
-			if c.rows[cy][cx]&bit != 0 {
-				// hit a rock
-				return false
-			}
+		if c.rows[cy]&bits != 0 {
+			// hit a rock
+			return false
 		}
 	}
 
 	return true
 }
 
+// hits tells if the rock rows, bottom row first, overlap the rocks in the
+// chamber from row bottom up.
+func (c *chamber) hits(shifted []uint64, bottom int) bool {
+	if bottom >= len(c.rows) {
+		return false
+	}
+	rows := c.rows[bottom:]
+	for ry, bits := range shifted {
+		if ry >= len(rows) {
+			return false
+		}
+		if rows[ry]&bits != 0 {
+			return true
+		}
+	}
+	return false
+}
+
 var downMove move = move{0, -1}
 
 type move struct {
@@ -137,7 +206,10 @@ type move struct {
 }
 
 type world struct {
+	cfg *worldConfig
+
 	rockSprites []*mask
+	rockMasks   []*rockMasks
 	spriteIdx   int
 
 	jets   []move
@@ -146,23 +218,27 @@ type world struct {
 	ch *chamber
 	r  *rock
 
-	rockCount *big.Int
+	rockCount int64
 }
 
-const spritesFile string = "data/rocks.txt"
-
-func NewWorld(jetsFile string) *world {
+func NewWorld(cfg *worldConfig) (*world, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 	w := &world{
-		spriteIdx: -1,
-		ch:        NewChamber(chamberWidth),
-		rockCount: big.NewInt(0),
+		cfg:         cfg,
+		rockSprites: cfg.sprites,
+		spriteIdx:   -1,
+		jets:        cfg.jets,
+		ch:          NewChamber(cfg.width),
+	}
+	for _, m := range cfg.sprites {
+		w.rockMasks = append(w.rockMasks, newRockMasks(m, cfg.width))
 	}
 
-	w.readSprites(spritesFile)
-	w.readJets(jetsFile)
 	w.nextRock()
 
-	return w
+	return w, nil
 }
 
 func (w *world) String() string {
@@ -195,15 +271,20 @@ func (w *world) moveRock(r *rock, mv move) bool {
 	return true
 }
 
+func (w *world) nextJet() move {
+	jet := w.jets[w.curJet]
+	w.curJet++
+	if w.curJet >= len(w.jets) {
+		w.curJet = 0
+	}
+	return jet
+}
+
 func (w *world) step() {
 	var nextMove move
 	switch w.r.moveType % 2 {
 	case 0:
-		nextMove = w.jets[w.curJet]
-		w.curJet++
-		if w.curJet >= len(w.jets) {
-			w.curJet = 0
-		}
+		nextMove = w.nextJet()
 
 	case 1:
 		nextMove = downMove
@@ -215,6 +296,62 @@ func (w *world) step() {
 	}
 }
 
+// dropRock moves the current rock until it gets stuck, same as calling step
+// over and over, then spawns the next rock.
+func (w *world) dropRock() {
+	r, ch := w.r, w.ch
+	if r.moveType == 0 {
+		// a new rock falls through spawnGap empty rows first, only the walls
+		// can stop the jets there
+		for i := 0; i < w.cfg.spawnGap; i++ {
+			x := r.x + w.nextJet().dx
+			if x >= 0 && x+r.masks.width <= ch.w {
+				r.x = x
+			}
+			r.y--
+			r.moveType += 2
+		}
+	}
+
+	// the hot loop works on locals: the rock's bitmasks at its current x,
+	// its bottom row, and the jet index
+	masks := r.masks
+	x, bottom := r.x, r.y-masks.height+1
+	maxX := ch.w - masks.width
+	shifted := masks.at(x)
+	jets, curJet := w.jets, w.curJet
+	moves := 0
+
+	jetTurn := r.moveType%2 == 0
+	for {
+		if jetTurn {
+			nx := x + jets[curJet].dx
+			curJet++
+			if curJet == len(jets) {
+				curJet = 0
+			}
+			if nx >= 0 && nx <= maxX {
+				if ns := masks.at(nx); !ch.hits(ns, bottom) {
+					x, shifted = nx, ns
+				}
+			}
+			moves++
+		}
+		jetTurn = true
+
+		if bottom == 0 || ch.hits(shifted, bottom-1) {
+			break
+		}
+		bottom--
+		moves++
+	}
+	w.curJet = curJet
+	r.x, r.y = x, bottom+masks.height-1
+	r.moveType += moves
+	ch.stampRock(r)
+	w.nextRock()
+}
+
 func (w *world) nextSprite() *mask {
 	if w.spriteIdx >= len(w.rockSprites)-1 {
 		w.spriteIdx = 0
@@ -224,73 +361,36 @@ func (w *world) nextSprite() *mask {
 	return w.rockSprites[w.spriteIdx]
 }
 
-var bigOne *big.Int = big.NewInt(1)
-
 func (w *world) nextRock() {
 	ns := w.nextSprite()
 
-	x := 2
-	y := w.ch.h + len(*ns) + 2
-
-	w.r = NewRock(ns, x, y)
-	w.rockCount.Add(w.rockCount, bigOne)
-}
+	x := w.cfg.spawnX
+	y := w.ch.h + w.cfg.spawnGap + len(*ns) - 1
 
-func (w *world) readJets(line string) {
-	moveMap := map[rune]move{
-		'<': {-1, 0},
-		'>': {+1, 0},
+	if w.r == nil {
+		w.r = newRockWithMasks(ns, w.rockMasks[w.spriteIdx], x, y)
+	} else {
+		// reuse the stuck rock, it is already stamped in the chamber
+		*w.r = rock{m: ns, masks: w.rockMasks[w.spriteIdx], x: x, y: y}
 	}
-	w.jets = lo.Map([]rune(line), func(item rune, index int) move {
-		return moveMap[item]
-	})
+	w.rockCount++
 }
 
-func (w *world) readSprites(fileName string) error {
-	runeMap := map[rune]uint8{
-		'.': 0,
-		'#': 1,
-	}
-
-	sprite := &mask{}
-	err := input.ReadFileLines(fileName, func(line string) error {
-		if len(line) == 0 {
-			w.rockSprites = append(w.rockSprites, sprite)
-			sprite = &mask{}
-			return nil
-		}
-
-		row := lo.Map([]rune(line), func(r rune, i int) uint8 {
-			return runeMap[r]
-		})
-		(*sprite) = append((*sprite), row)
-
-		return nil
-	})
+func processFile(fileName string, rockCount *big.Int) (*big.Int, error) {
+	cfg, err := readWorldConfig(spritesFile, fileName)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	// append the last rock
-	w.rockSprites = append(w.rockSprites, sprite)
-	return nil
-}
 
-func processFile(fileName string, rockCount *big.Int) (*big.Int, error) {
-	var jets string
-	err := input.ReadFileLines(fileName, func(line string) error {
-		jets = line
-		return nil
-	})
+	w, err := NewWorld(cfg)
 	if err != nil {
 		return nil, err
 	}
-
-	w := NewWorld(jets)
-	for {
-		w.step()
-		if w.rockCount.Cmp(rockCount) >= 0 {
-			break
-		}
+	if !rockCount.IsInt64() {
+		return nil, errors.New("too many rocks " + rockCount.String())
+	}
+	for w.rockCount < rockCount.Int64() {
+		w.dropRock()
 	}
 
 	return w.ch.maskStart.Add(w.ch.maskStart, big.NewInt(int64(w.ch.h))), nil
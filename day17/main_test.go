@@ -1,13 +1,23 @@
 package main
 
 import (
+	"math/big"
+	"strings"
 	"testing"
 
 	"kfet.org/aoc_common/assert"
 )
 
+func newTestWorld() *world {
+	cfg, err := readWorldConfig(spritesFile, "data/part_one.txt")
+	assert.NoErr(err)
+	w, err := NewWorld(cfg)
+	assert.NoErr(err)
+	return w
+}
+
 func TestMove(t *testing.T) {
-	w := NewWorld("data/part_one.txt")
+	w := newTestWorld()
 
 	assert.Equals(0, w.r.moveType, "")
 	w.step()
@@ -20,7 +30,7 @@ func TestMove(t *testing.T) {
 }
 
 func TestChamberTestMove(t *testing.T) {
-	w := NewWorld("data/part_one.txt")
+	w := newTestWorld()
 	r := NewRock(w.rockSprites[0], 0, 0)
 
 	ch := NewChamber(7)
@@ -58,3 +68,142 @@ func TestChamberStampRock(t *testing.T) {
 	assert.False(ch.testMove(rTest, move{1, 0}))
 	assert.True(ch.testMove(rTest, move{2, 0}))
 }
+
+func TestCustomConfig(t *testing.T) {
+	sprites, err := readSprites(strings.NewReader("#\n"))
+	assert.NoErr(err)
+	jets, err := readJets(strings.NewReader(">\n"))
+	assert.NoErr(err)
+
+	cfg := NewWorldConfig(sprites, jets)
+	cfg.width = 3
+	cfg.spawnX = 0
+	cfg.spawnGap = 0
+
+	w, err := NewWorld(cfg)
+	assert.NoErr(err)
+	for w.rockCount <= 10 {
+		w.step()
+	}
+	// every rock is pushed once to the middle column and lands there
+	assert.Equals(10, w.ch.h, "")
+	assert.Equals(strings.Repeat(".#.\n", 10), w.ch.String(), "")
+}
+
+func TestConfigErrors(t *testing.T) {
+	_, err := readSprites(strings.NewReader("#x#\n"))
+	assert.True(err != nil)
+	_, err = readJets(strings.NewReader("<>v\n"))
+	assert.True(err != nil)
+
+	sprites, _ := readSprites(strings.NewReader("####\n"))
+	cfg := NewWorldConfig(sprites, []move{{1, 0}})
+	cfg.width = 5
+	_, err = NewWorld(cfg)
+	assert.True(err != nil)
+
+	_, err = readWorldConfig("data/missing.txt", "data/part_one.txt")
+	assert.True(err != nil)
+}
+
+func TestProcessFile(t *testing.T) {
+	// rockCount counts the rock still falling, so 2023 drops 2022 rocks
+	res, err := processFile("data/part_one.txt", big.NewInt(2023))
+	assert.NoErr(err)
+	assert.Equals(int64(3068), res.Int64(), "")
+
+	res, err = processFile("data/input.txt", big.NewInt(2023))
+	assert.NoErr(err)
+	assert.Equals(int64(3200), res.Int64(), "")
+}
+
+// cellHeight is the tower height after dropping rocks with the chamber kept
+// as one byte per cell, trimmed at full rows only, the way it was before the
+// rows became bitmasks. It is the baseline for BenchmarkSimulation.
+func cellHeight(cfg *worldConfig, rocks int) int {
+	var rows [][]uint8
+	var trimmed int
+	fits := func(m *mask, x, y int) bool {
+		for ry, rr := range *m {
+			cy := y - ry
+			if cy < 0 {
+				return false
+			}
+			for rx, bit := range rr {
+				cx := x + rx
+				if cx < 0 || cx >= cfg.width {
+					return false
+				}
+				if cy < len(rows) && rows[cy][cx]&bit != 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	jet := 0
+	for i := 0; i < rocks; i++ {
+		m := cfg.sprites[i%len(cfg.sprites)]
+		x, y := cfg.spawnX, len(rows)+len(*m)+cfg.spawnGap-1
+		for {
+			mv := cfg.jets[jet]
+			jet = (jet + 1) % len(cfg.jets)
+			if fits(m, x+mv.dx, y) {
+				x += mv.dx
+			}
+			if !fits(m, x, y-1) {
+				break
+			}
+			y--
+		}
+
+		for len(rows) < y+1 {
+			rows = append(rows, make([]uint8, cfg.width))
+		}
+		floor := 0
+		for ry, rr := range *m {
+			cy := y - ry
+			for rx, bit := range rr {
+				rows[cy][x+rx] |= bit
+			}
+			full := true
+			for _, c := range rows[cy] {
+				full = full && c != 0
+			}
+			if full && cy > floor {
+				floor = cy
+			}
+		}
+		rows = rows[floor:]
+		trimmed += floor
+	}
+	return trimmed + len(rows)
+}
+
+func TestCellHeight(t *testing.T) {
+	cfg, err := readWorldConfig(spritesFile, "data/input.txt")
+	assert.NoErr(err)
+	assert.Equals(3200, cellHeight(cfg, 2022), "")
+}
+
+// BenchmarkSimulation compares the bitmask chamber with the byte per cell
+// one it replaced.
+func BenchmarkSimulation(b *testing.B) {
+	cfg, err := readWorldConfig(spritesFile, "data/input.txt")
+	assert.NoErr(err)
+	b.Run("cells", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			assert.Equals(3200, cellHeight(cfg, 2022), "")
+		}
+	})
+	b.Run("bitmask", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			w, _ := NewWorld(cfg)
+			for w.rockCount < 2023 {
+				w.dropRock()
+			}
+			assert.Equals(int64(3200), w.ch.maskStart.Int64()+int64(w.ch.h), "")
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// isOutsideBox tells if c is beyond the bounding box grown by one cube on all
+// sides, which the exterior flood fill does not need to visit.
+func (cm *coordsMap) isOutsideBox(c *cube) bool {
+	return c.x < cm.minX-1 || c.x > cm.maxX+1 ||
+		c.y < cm.minY-1 || c.y > cm.maxY+1 ||
+		c.z < cm.minZ-1 || c.z > cm.maxZ+1
+}
+
+// fillExterior floods the air around the droplet once, starting from a corner
+// outside the bounding box. Any cube of the bounding box not reached is air
+// trapped inside the droplet.
+func (cm *coordsMap) fillExterior() {
+	if len(cm.m) == 0 {
+		return
+	}
+	start := &cube{cm.minX - 1, cm.minY - 1, cm.minZ - 1}
+	// set would grow the bounding box, so the exterior air is kept aside
+	// until the fill is done
+	exterior := map[cube]struct{}{*start: {}}
+	wave := []*cube{start}
+	for len(wave) > 0 {
+		nextWave := []*cube{}
+		for _, c := range wave {
+			for _, n := range c.neighbours() {
+				if cm.isOutsideBox(n) {
+					continue
+				}
+				if _, ok := exterior[*n]; ok {
+					continue
+				}
+				if m, set := cm.get(n); set && m == lava {
+					continue
+				}
+				exterior[*n] = struct{}{}
+				nextWave = append(nextWave, n)
+			}
+		}
+		wave = nextWave
+	}
+
+	cm.eachInBox(func(c *cube) {
+		if _, set := cm.get(c); set {
+			return
+		}
+		if _, ok := exterior[*c]; ok {
+			cm.set(c, air)
+		} else {
+			cm.set(c, pocket_air)
+		}
+	})
+}
+
+// eachInBox visits the bounding box in x, y, z order.
+func (cm *coordsMap) eachInBox(visit func(c *cube)) {
+	for x := cm.minX; x <= cm.maxX; x++ {
+		for y := cm.minY; y <= cm.maxY; y++ {
+			for z := cm.minZ; z <= cm.maxZ; z++ {
+				visit(&cube{x, y, z})
+			}
+		}
+	}
+}
+
+func (cm *coordsMap) count(m material) int {
+	count := 0
+	cm.eachInBox(func(c *cube) {
+		if cm.is(c, m) {
+			count++
+		}
+	})
+	return count
+}
+
+func (cm *coordsMap) is(c *cube, m material) bool {
+	got, set := cm.get(c)
+	return set && got == m
+}
+
+// interiorVolume is the number of cubes enclosed by the exterior surface:
+// the lava and the air trapped in it.
+func (cm *coordsMap) interiorVolume() int {
+	return cm.count(lava) + cm.count(pocket_air)
+}
+
+// airPocket is a connected component of trapped air. cubes are in x, y, z
+// order.
+type airPocket struct {
+	cubes []cube
+}
+
+func (p *airPocket) size() int {
+	return len(p.cubes)
+}
+
+// airPockets returns the pockets of trapped air, biggest first, ties broken
+// on the first cube.
+func (cm *coordsMap) airPockets() []*airPocket {
+	res := []*airPocket{}
+	seen := map[cube]struct{}{}
+	cm.eachInBox(func(c *cube) {
+		if _, ok := seen[*c]; ok || !cm.is(c, pocket_air) {
+			return
+		}
+		p := &airPocket{}
+		seen[*c] = struct{}{}
+		wave := []*cube{c}
+		for len(wave) > 0 {
+			nextWave := []*cube{}
+			for _, wc := range wave {
+				p.cubes = append(p.cubes, *wc)
+				for _, n := range wc.neighbours() {
+					if _, ok := seen[*n]; ok || !cm.is(n, pocket_air) {
+						continue
+					}
+					seen[*n] = struct{}{}
+					nextWave = append(nextWave, n)
+				}
+			}
+			wave = nextWave
+		}
+		sort.Slice(p.cubes, func(i, j int) bool { return p.cubes[i].less(&p.cubes[j]) })
+		res = append(res, p)
+	})
+	sort.SliceStable(res, func(i, j int) bool { return res[i].size() > res[j].size() })
+	return res
+}
+
+func (c *cube) less(o *cube) bool {
+	if c.x != o.x {
+		return c.x < o.x
+	}
+	if c.y != o.y {
+		return c.y < o.y
+	}
+	return c.z < o.z
+}
+
+// zSlice draws the layer z of the bounding box, x to the right and y down:
+// '#' for lava, 'o' for trapped air and '.' for exterior air.
+func (cm *coordsMap) zSlice(z int) string {
+	var sb strings.Builder
+	for y := cm.minY; y <= cm.maxY; y++ {
+		for x := cm.minX; x <= cm.maxX; x++ {
+			m, _ := cm.get(&cube{x, y, z})
+			switch m {
+			case lava:
+				sb.WriteRune('#')
+			case pocket_air:
+				sb.WriteRune('o')
+			default:
+				sb.WriteRune('.')
+			}
+		}
+		sb.WriteRune('\n')
+	}
+	return sb.String()
+}
+
+// slicesString draws all the layers of the droplet, bottom first.
+func (cm *coordsMap) slicesString() string {
+	var sb strings.Builder
+	for z := cm.minZ; z <= cm.maxZ; z++ {
+		sb.WriteString(fmt.Sprintf("z=%d\n%s\n", z, cm.zSlice(z)))
+	}
+	return sb.String()
+}
@@ -105,95 +105,24 @@ func (c *cube) neighbours() []*cube {
 	return res
 }
 
+// countFreeSides counts the sides of c not touching lava. With
+// handleAirPockets, sides touching air trapped inside the droplet are not
+// free either. The air must have been classified by fillExterior.
 func (cm *coordsMap) countFreeSides(c *cube, handleAirPockets bool) int {
 	return lo.Reduce(c.neighbours(), func(agg int, item *cube, index int) int {
-		if m, set := cm.get(item); set && m == air {
+		m, set := cm.get(item)
+		switch {
+		case !set || m == air:
+			return agg + 1
+		case m == pocket_air && !handleAirPockets:
+			// consider it a free side
 			return agg + 1
-		} else if !set {
-			if !handleAirPockets {
-				// consider it a free side
-				return agg + 1
-			}
-			if cm.expandAir(item) {
-				return agg + 1
-			}
 		}
 		return agg
 	}, 0)
 }
 
-// true if air, false if internal pocket or unknown yet
-func (cm *coordsMap) expandAir(c *cube) bool {
-	// BFS
-	visited := map[cube]struct{}{}
-	wave := []*cube{c}
-	nextWave := []*cube{}
-
-	// keep track of unknown so we can fill with air/pocket air, once determined
-	backQueue := []*cube{}
-
-	var fillMaterial material
-	for fill := false; !fill; {
-		for _, nextCube := range wave {
-			if _, skip := visited[*nextCube]; skip {
-				// skip visited (break cycles)
-				continue
-			}
-			visited[*nextCube] = struct{}{}
-
-			if cm.isBeyondLava(nextCube) {
-				// set all to air
-				fill = true
-				fillMaterial = air
-				break
-			}
-
-			if m, determined := cm.get(nextCube); determined {
-				if m == lava {
-					// hit lava, skip to next cube
-					continue
-				}
-				// m is pocket air or air - set all to the same
-				fill = true
-				fillMaterial = m
-				break
-			}
-
-			// not set so it is unknown, add to back queue
-			backQueue = append(backQueue, nextCube)
-			nextWave = append(nextWave, nextCube.neighbours()...)
-		}
-
-		wave = nextWave
-		nextWave = []*cube{}
-
-		if len(wave) == 0 && !fill {
-			// no more cubes to expand, must be pocket air
-			fill = true
-			fillMaterial = pocket_air
-		}
-	}
-	// fill
-	for _, nc := range backQueue {
-		cm.set(nc, fillMaterial)
-	}
-	if fillMaterial == air {
-		return true
-	}
-
-	return false
-}
-
-func (cm *coordsMap) isBeyondLava(c *cube) bool {
-	if c.x < cm.minX || c.x > cm.maxX ||
-		c.y < cm.minY || c.y > cm.maxY ||
-		c.z < cm.minZ || c.z > cm.maxZ {
-		return true
-	}
-	return false
-}
-
-func processFile(fileName string, handleAirPockets bool) (int, error) {
+func readCoordsMap(fileName string) (*coordsMap, error) {
 	cm := NewCoordsMap()
 
 	err := input.ReadFileLines(fileName, func(line string) error {
@@ -202,6 +131,16 @@ func processFile(fileName string, handleAirPockets bool) (int, error) {
 		cm.set(c, lava)
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	cm.fillExterior()
+
+	return cm, nil
+}
+
+func processFile(fileName string, handleAirPockets bool) (int, error) {
+	cm, err := readCoordsMap(fileName)
 	if err != nil {
 		return 0, err
 	}
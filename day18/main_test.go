@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParts(t *testing.T) {
+	res, err := processFile("data/part_one.txt", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 64, res)
+
+	res, err = processFile("data/part_one.txt", true)
+	assert.Nil(t, err)
+	assert.Equal(t, 58, res)
+
+	res, err = processFile("data/input.txt", true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2062, res)
+}
+
+func TestAirPockets(t *testing.T) {
+	cm, err := readCoordsMap("data/part_one.txt")
+	assert.Nil(t, err)
+
+	pockets := cm.airPockets()
+	assert.Equal(t, 1, len(pockets))
+	assert.Equal(t, []cube{{2, 2, 5}}, pockets[0].cubes)
+	assert.Equal(t, 14, cm.interiorVolume())
+
+	assert.Equal(t, "...\n.#.\n...\n", cm.zSlice(1))
+	assert.Equal(t, ".#.\n#o#\n.#.\n", cm.zSlice(5))
+}
+
+func TestHollowCube(t *testing.T) {
+	// a 4x4x4 shell around a 2x2x2 pocket, plus a separate single cube pocket
+	cm := NewCoordsMap()
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			for z := 0; z < 4; z++ {
+				if x == 0 || x == 3 || y == 0 || y == 3 || z == 0 || z == 3 {
+					cm.set(&cube{x, y, z}, lava)
+				}
+			}
+		}
+	}
+	for _, c := range (&cube{10, 10, 10}).neighbours() {
+		cm.set(c, lava)
+	}
+	cm.fillExterior()
+
+	pockets := cm.airPockets()
+	assert.Equal(t, 2, len(pockets))
+	assert.Equal(t, 8, pockets[0].size())
+	assert.Equal(t, []cube{{10, 10, 10}}, pockets[1].cubes)
+	assert.Equal(t, 56+8+6+1, cm.interiorVolume())
+}
+
+func TestMesh(t *testing.T) {
+	cm, err := readCoordsMap("data/input.txt")
+	assert.Nil(t, err)
+
+	area := func(quads []quad) int {
+		res := 0
+		for _, q := range quads {
+			res += q.area()
+		}
+		return res
+	}
+	faces := cm.surface(false)
+	assert.Equal(t, 2062, len(faces))
+	merged := cm.surface(true)
+	assert.Equal(t, 2062, area(merged))
+	assert.Less(t, len(merged), len(faces))
+
+	var obj, stl bytes.Buffer
+	assert.Nil(t, writeOBJ(&obj, merged))
+	assert.Nil(t, writeSTL(&stl, "droplet", merged))
+	assert.Equal(t, len(merged), strings.Count(obj.String(), "\nf "))
+	assert.Equal(t, 2*len(merged), strings.Count(stl.String(), "facet normal"))
+}
+
+func TestMeshCube(t *testing.T) {
+	// a 2x2x2 cube merges into its 6 sides
+	cm := NewCoordsMap()
+	for _, c := range []cube{{0, 0, 0}, {0, 0, 1}, {0, 1, 0}, {0, 1, 1}, {1, 0, 0}, {1, 0, 1}, {1, 1, 0}, {1, 1, 1}} {
+		cm.set(&c, lava)
+	}
+	cm.fillExterior()
+	quads := cm.surface(true)
+	assert.Equal(t, 6, len(quads))
+
+	var obj bytes.Buffer
+	assert.Nil(t, writeOBJ(&obj, quads))
+	assert.Equal(t, 8, strings.Count(obj.String(), "v "))
+
+	for _, q := range quads {
+		assert.Equal(t, 4, q.area())
+		// the normal points away from the cube center at 1, 1, 1
+		c := q.corners()
+		var e1, e2 [3]int
+		for i := 0; i < 3; i++ {
+			e1[i] = c[1][i] - c[0][i]
+			e2[i] = c[2][i] - c[1][i]
+		}
+		cross := [3]int{
+			e1[1]*e2[2] - e1[2]*e2[1],
+			e1[2]*e2[0] - e1[0]*e2[2],
+			e1[0]*e2[1] - e1[1]*e2[0],
+		}
+		n := q.normal()
+		assert.Equal(t, 4*n[q.axis], cross[q.axis])
+		assert.Equal(t, 1+n[q.axis], q.plane)
+	}
+}
+
+func TestExportFile(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, exportFile("data/part_one.txt", &buf, "stl", false))
+	assert.True(t, strings.HasPrefix(buf.String(), "solid droplet\n"))
+	assert.Equal(t, 2*58, strings.Count(buf.String(), "facet normal"))
+
+	assert.NotNil(t, exportFile("data/part_one.txt", &buf, "ply", false))
+}
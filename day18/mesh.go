@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// quad is a rectangle on the surface of the droplet, facing along axis (0 for
+// x, 1 for y, 2 for z), towards +1 or -1. It lies in the plane axis = plane
+// and spans [u0, u1] x [v0, v1] on the next two axes, in x, y, z cyclic
+// order.
+type quad struct {
+	axis, dir int
+	plane     int
+	u0, v0    int
+	u1, v1    int
+}
+
+func (q *quad) area() int {
+	return (q.u1 - q.u0) * (q.v1 - q.v0)
+}
+
+// corners returns the corners counter clockwise seen from outside, so that
+// the normal points away from the lava.
+func (q *quad) corners() [4][3]int {
+	point := func(u, v int) [3]int {
+		var p [3]int
+		p[q.axis] = q.plane
+		p[(q.axis+1)%3] = u
+		p[(q.axis+2)%3] = v
+		return p
+	}
+	if q.dir > 0 {
+		return [4][3]int{point(q.u0, q.v0), point(q.u1, q.v0), point(q.u1, q.v1), point(q.u0, q.v1)}
+	}
+	return [4][3]int{point(q.u0, q.v0), point(q.u0, q.v1), point(q.u1, q.v1), point(q.u1, q.v0)}
+}
+
+func (q *quad) normal() [3]int {
+	var n [3]int
+	n[q.axis] = q.dir
+	return n
+}
+
+func (c *cube) coord(axis int) int {
+	return [3]int{c.x, c.y, c.z}[axis]
+}
+
+// isExterior tells if a lava side facing c is on the exterior surface.
+func (cm *coordsMap) isExterior(c *cube) bool {
+	m, set := cm.get(c)
+	return !set || m == air
+}
+
+type sliceKey struct {
+	axis, dir, plane int
+}
+
+// exteriorFaces returns the unit faces of the lava touching exterior air,
+// the ones counted by countFreeSides with handleAirPockets, in a stable
+// order.
+func (cm *coordsMap) exteriorFaces() []quad {
+	res := []quad{}
+	cm.eachInBox(func(c *cube) {
+		if !cm.is(c, lava) {
+			return
+		}
+		for i, n := range c.neighbours() {
+			// neighbours come in -x, +x, -y, +y, -z, +z order
+			axis, dir := i/2, i%2*2-1
+			if !cm.isExterior(n) {
+				continue
+			}
+			plane := c.coord(axis)
+			if dir > 0 {
+				plane++
+			}
+			u, v := c.coord((axis+1)%3), c.coord((axis+2)%3)
+			res = append(res, quad{
+				axis: axis, dir: dir, plane: plane,
+				u0: u, v0: v, u1: u + 1, v1: v + 1,
+			})
+		}
+	})
+	return res
+}
+
+// mergeFaces greedily joins unit faces lying in the same plane and facing the
+// same way into rectangles: first along u, then along v as long as the whole
+// row matches. Merged rectangles may meet their neighbours in T-junctions.
+func mergeFaces(faces []quad) []quad {
+	type slice struct {
+		cells map[[2]int]bool
+		faces []quad
+	}
+	slices := map[sliceKey]*slice{}
+	order := []sliceKey{}
+	for _, f := range faces {
+		k := sliceKey{f.axis, f.dir, f.plane}
+		if _, ok := slices[k]; !ok {
+			slices[k] = &slice{cells: map[[2]int]bool{}}
+			order = append(order, k)
+		}
+		slices[k].cells[[2]int{f.u0, f.v0}] = true
+		slices[k].faces = append(slices[k].faces, f)
+	}
+
+	res := []quad{}
+	for _, k := range order {
+		cells := slices[k].cells
+		// start from the cells in the order they came in, so the result is
+		// stable
+		for _, f := range slices[k].faces {
+			if !cells[[2]int{f.u0, f.v0}] {
+				continue
+			}
+			u1 := f.u0 + 1
+			for cells[[2]int{u1, f.v0}] {
+				u1++
+			}
+			v1 := f.v0 + 1
+			for {
+				full := true
+				for u := f.u0; u < u1; u++ {
+					if !cells[[2]int{u, v1}] {
+						full = false
+						break
+					}
+				}
+				if !full {
+					break
+				}
+				v1++
+			}
+			for u := f.u0; u < u1; u++ {
+				for v := f.v0; v < v1; v++ {
+					delete(cells, [2]int{u, v})
+				}
+			}
+			res = append(res, quad{
+				axis: k.axis, dir: k.dir, plane: k.plane,
+				u0: f.u0, v0: f.v0, u1: u1, v1: v1,
+			})
+		}
+	}
+	return res
+}
+
+// surface returns the exterior surface of the droplet as quads, merged or one
+// per cube face.
+func (cm *coordsMap) surface(merge bool) []quad {
+	faces := cm.exteriorFaces()
+	if merge {
+		return mergeFaces(faces)
+	}
+	return faces
+}
+
+// writeOBJ writes the quads as a Wavefront OBJ mesh, sharing the vertices.
+func writeOBJ(w io.Writer, quads []quad) error {
+	bw := bufio.NewWriter(w)
+	vertices := map[[3]int]int{}
+	faces := make([][4]int, 0, len(quads))
+	for _, q := range quads {
+		var f [4]int
+		for i, p := range q.corners() {
+			idx, ok := vertices[p]
+			if !ok {
+				// OBJ indexes from 1
+				idx = len(vertices) + 1
+				vertices[p] = idx
+				fmt.Fprintf(bw, "v %d %d %d\n", p[0], p[1], p[2])
+			}
+			f[i] = idx
+		}
+		faces = append(faces, f)
+	}
+	for _, f := range faces {
+		fmt.Fprintf(bw, "f %d %d %d %d\n", f[0], f[1], f[2], f[3])
+	}
+	return bw.Flush()
+}
+
+// writeSTL writes the quads as an ASCII STL solid, two triangles per quad.
+func writeSTL(w io.Writer, name string, quads []quad) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "solid %s\n", name)
+	for _, q := range quads {
+		n := q.normal()
+		c := q.corners()
+		for _, tri := range [2][3]int{{0, 1, 2}, {0, 2, 3}} {
+			fmt.Fprintf(bw, "  facet normal %d %d %d\n    outer loop\n", n[0], n[1], n[2])
+			for _, i := range tri {
+				fmt.Fprintf(bw, "      vertex %d %d %d\n", c[i][0], c[i][1], c[i][2])
+			}
+			fmt.Fprintf(bw, "    endloop\n  endfacet\n")
+		}
+	}
+	fmt.Fprintf(bw, "endsolid %s\n", name)
+	return bw.Flush()
+}
+
+// exportFile writes the exterior surface of the droplet in the file as "obj"
+// or "stl".
+func exportFile(fileName string, w io.Writer, format string, merge bool) error {
+	cm, err := readCoordsMap(fileName)
+	if err != nil {
+		return err
+	}
+	quads := cm.surface(merge)
+	switch format {
+	case "obj":
+		return writeOBJ(w, quads)
+	case "stl":
+		return writeSTL(w, "droplet", quads)
+	}
+	return errors.New("unknown mesh format " + format)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func (b *blueprint) materialName(m material) string {
+	if int(m) < len(b.materials) {
+		return b.materials[m]
+	}
+	return fmt.Sprint("material ", int(m))
+}
+
+// buildStep starts building a robot at the beginning of a minute, counting
+// from 1. The robot is ready at the end of that minute.
+type buildStep struct {
+	minute int
+	robot  material
+}
+
+type buildOrder []buildStep
+
+// buildOrder reads the robots built along a chain of states returned by
+// maxGoods.
+func (r *blueprintResult) buildOrder(timeToRun int) buildOrder {
+	order := buildOrder{}
+	for i := 1; i < len(r.path); i++ {
+		prev, cur := r.path[i-1].ws, r.path[i].ws
+		for m := range cur.robots {
+			if cur.robots[m] > prev.robots[m] {
+				order = append(order, buildStep{
+					minute: timeToRun - r.path[i].timeLeft,
+					robot:  material(m),
+				})
+			}
+		}
+	}
+	return order
+}
+
+// minuteReport is the factory at the end of a minute.
+type minuteReport struct {
+	minute int
+	// built is the robot started this minute, if any
+	built  *material
+	robots []int
+	goods  []int
+}
+
+// replay runs the build order minute by minute against the blueprint. It
+// fails if a robot is built out of order, outside the time or without the
+// goods to pay for it.
+func (bp *blueprint) replay(order buildOrder, timeToRun int) ([]minuteReport, error) {
+	ws := newWorldState(bp)
+	res := []minuteReport{}
+	next := 0
+	for minute := 1; minute <= timeToRun; minute++ {
+		var built *material
+		if next < len(order) && order[next].minute < minute {
+			return nil, fmt.Errorf("step %d: robot built at minute %d, out of order", next+1, order[next].minute)
+		}
+		if next < len(order) && order[next].minute == minute {
+			step := order[next]
+			if int(step.robot) >= len(bp.robotCost) || bp.robotCost[step.robot] == nil {
+				return nil, fmt.Errorf("step %d: unknown robot %s", next+1, bp.materialName(step.robot))
+			}
+			rc := bp.robotCost[step.robot]
+			for m, c := range rc {
+				if ws.goods[m] < c {
+					return nil, fmt.Errorf("step %d: minute %d: %s robot needs %d %s, have %d",
+						next+1, minute, bp.materialName(step.robot), c, bp.materialName(material(m)), ws.goods[m])
+				}
+			}
+			for m, c := range rc {
+				ws.goods[m] -= c
+			}
+			robot := step.robot
+			built = &robot
+			next++
+		}
+
+		// the robots working this minute mine, the new one joins at the end
+		ws.mine(1)
+		if built != nil {
+			ws.robots[*built]++
+		}
+		res = append(res, minuteReport{
+			minute: minute,
+			built:  built,
+			robots: append([]int(nil), ws.robots...),
+			goods:  append([]int(nil), ws.goods...),
+		})
+	}
+	if next < len(order) {
+		return nil, fmt.Errorf("step %d: robot built at minute %d, after the end", next+1, order[next].minute)
+	}
+	return res, nil
+}
+
+// verify replays the build order and checks it produces the claimed amount of
+// the target material.
+func (bp *blueprint) verify(order buildOrder, timeToRun int, target material, claimed int) error {
+	minutes, err := bp.replay(order, timeToRun)
+	if err != nil {
+		return err
+	}
+	got := 0
+	if len(minutes) > 0 {
+		got = minutes[len(minutes)-1].goods[target]
+	}
+	if got != claimed {
+		return fmt.Errorf("blueprint %d: build order yields %d %s, not %d", bp.id, got, bp.materialName(target), claimed)
+	}
+	return nil
+}
+
+func (b *blueprint) countsString(counts []int) string {
+	parts := make([]string, len(counts))
+	for m, c := range counts {
+		parts[m] = fmt.Sprint(c, " ", b.materialName(material(m)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func writeReport(w io.Writer, bp *blueprint, minutes []minuteReport) error {
+	if _, err := fmt.Fprintf(w, "Blueprint %d\n", bp.id); err != nil {
+		return err
+	}
+	for _, mr := range minutes {
+		built := "nothing built"
+		if mr.built != nil {
+			built = fmt.Sprint("built ", bp.materialName(*mr.built), " robot")
+		}
+		_, err := fmt.Fprintf(w, "\n== Minute %d ==\n%s\nrobots: %s\ngoods: %s\n",
+			mr.minute, built, bp.countsString(mr.robots), bp.countsString(mr.goods))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonCounts map[string]int
+
+func (b *blueprint) toJSONCounts(counts []int) jsonCounts {
+	res := jsonCounts{}
+	for m, c := range counts {
+		res[b.materialName(material(m))] = c
+	}
+	return res
+}
+
+type jsonMinute struct {
+	Minute int        `json:"minute"`
+	Built  string     `json:"built,omitempty"`
+	Robots jsonCounts `json:"robots"`
+	Goods  jsonCounts `json:"goods"`
+}
+
+type jsonReport struct {
+	Blueprint int          `json:"blueprint"`
+	Minutes   []jsonMinute `json:"minutes"`
+}
+
+func writeReportJSON(w io.Writer, bp *blueprint, minutes []minuteReport) error {
+	rep := jsonReport{Blueprint: bp.id, Minutes: []jsonMinute{}}
+	for _, mr := range minutes {
+		jm := jsonMinute{
+			Minute: mr.minute,
+			Robots: bp.toJSONCounts(mr.robots),
+			Goods:  bp.toJSONCounts(mr.goods),
+		}
+		if mr.built != nil {
+			jm.Built = bp.materialName(*mr.built)
+		}
+		rep.Minutes = append(rep.Minutes, jm)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// explainFile writes the optimal build order of every blueprint in the file,
+// as text or JSON.
+func explainFile(fileName string, target string, timeToRun int, w io.Writer, asJSON bool) error {
+	states, err := readStates(fileName)
+	if err != nil {
+		return err
+	}
+	results, err := evaluate(states, target, timeToRun)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		bp := states[i].blueprint
+		minutes, err := bp.replay(r.buildOrder(timeToRun), timeToRun)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			err = writeReportJSON(w, bp, minutes)
+		} else {
+			err = writeReport(w, bp, minutes)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,28 +1,25 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
-	"time"
 
 	"kfet.org/aoc_common/assert"
 	"kfet.org/aoc_common/calc"
 	"kfet.org/aoc_common/input"
 )
 
-type material uint8
-
-const (
-	ore = iota
-	clay
-	obsidian
-	geode
-	materialsCount
-)
+// material indexes the materials of a blueprint, in the order their robots
+// are first listed.
+type material int
 
 type blueprint struct {
 	id        int
-	robotCost []robotCost // indexed by material
+	materials []string
+	robotCost []robotCost // indexed by material, nil if there is no such robot
 	robotMax  []int       // max number of each robot type, indexed by material
 }
 
@@ -34,13 +31,23 @@ type worldState struct {
 	goods     []int
 }
 
-func NewState(blueprintLine string) *worldState {
+func NewState(blueprintLine string) (*worldState, error) {
+	bp, err := parseBlueprint(blueprintLine)
+	if err != nil {
+		return nil, err
+	}
+	return newWorldState(bp), nil
+}
+
+// newWorldState is the factory at the start: a single robot of the first
+// type listed, the ore robot in the puzzle.
+func newWorldState(bp *blueprint) *worldState {
 	s := &worldState{
-		blueprint: parseBlueprint(blueprintLine),
-		robots:    make([]int, materialsCount),
-		goods:     make([]int, materialsCount),
+		blueprint: bp,
+		robots:    make([]int, len(bp.materials)),
+		goods:     make([]int, len(bp.materials)),
 	}
-	s.robots[ore] = 1
+	s.robots[0] = 1
 	return s
 }
 
@@ -113,6 +120,10 @@ func (ws *worldState) nextStates(timeLeft int, target material) []*nextState {
 	res := []*nextState{}
 
 	for mat, rCost := range ws.blueprint.robotCost {
+		if rCost == nil {
+			// nothing mines this material
+			continue
+		}
 		if material(mat) != target &&
 			ws.robots[mat] >= ws.blueprint.robotMax[mat] {
 			// already saturated this type of robot
@@ -129,13 +140,6 @@ func (ws *worldState) nextStates(timeLeft int, target material) []*nextState {
 
 		if waitAndBuildTime > timeLeft {
 			// not enough time to build this type of bot
-			// just mine until end of time
-			endWs := ws.copyState()
-			endWs.mine(timeLeft)
-			res = append(res, &nextState{
-				ws:       endWs,
-				timeLeft: 0,
-			})
 			continue
 		}
 
@@ -148,73 +152,124 @@ func (ws *worldState) nextStates(timeLeft int, target material) []*nextState {
 		})
 	}
 
+	// building nothing more and just mining until end of time is always a
+	// choice, spending goods on robots might not pay off for the target
+	endWs := ws.copyState()
+	endWs.mine(timeLeft)
+	res = append([]*nextState{{
+		ws:       endWs,
+		timeLeft: 0,
+	}}, res...)
+
 	return res
 }
 
 func (ws *worldState) upperLimitGoods(timeLeft int, mat material) int {
 	return ws.goods[mat] +
 		timeLeft*ws.robots[mat] +
-		timeLeft*timeLeft/2
+		timeLeft*(timeLeft-1)/2
 }
 
-func (ws *worldState) maxGoods(timeLeft int, target material, minGoods int) (int, []*nextState) {
-	if timeLeft == 0 {
-		// at end of search, return goods produced
-		return ws.goods[target], []*nextState{{ws: ws, timeLeft: timeLeft}}
-	}
-
-	if ws.upperLimitGoods(timeLeft, target) < minGoods {
-		// theoretical maximum lower than required minimum
-		return 0, nil
-	}
+// maxGoods returns the most of the target material that can be had in the
+// time left, and the chain of states leading to it.
+func (ws *worldState) maxGoods(timeLeft int, target material) (int, []*nextState) {
+	s := newSearch(target)
+	s.explore(&nextState{ws: ws, timeLeft: timeLeft})
+	return s.best, s.path
+}
 
-	max := minGoods
-	var maxWs []*nextState
-	for _, ns := range ws.nextStates(timeLeft, target) {
-		nextMax, mws := ns.ws.maxGoods(ns.timeLeft, target, max)
-		if nextMax > max {
-			max = nextMax
-			maxWs = mws
+// material returns the index of the named material.
+func (b *blueprint) material(name string) (material, bool) {
+	for m, n := range b.materials {
+		if n == name {
+			return material(m), true
 		}
 	}
+	return 0, false
+}
 
-	return max, append([]*nextState{{ws: ws, timeLeft: timeLeft}}, maxWs...)
+func (b *blueprint) addMaterial(name string) material {
+	if m, ok := b.material(name); ok {
+		return m
+	}
+	b.materials = append(b.materials, name)
+	return material(len(b.materials) - 1)
 }
 
 // "Blueprint 1: Each ore robot costs 4 ore. Each clay robot costs 2 ore. Each obsidian robot costs 3 ore and 14 clay. Each geode robot costs 2 ore and 7 obsidian."
-func parseBlueprint(line string) *blueprint {
-	tokens := strings.Split(line, " ")
-	bp := &blueprint{
-		id: input.MustAtoi(tokens[1][0 : len(tokens[1])-1]),
-		robotCost: []robotCost{
-			make([]int, materialsCount), // ore
-			make([]int, materialsCount), // clay
-			make([]int, materialsCount), // obsidian
-			make([]int, materialsCount), // geode
-		},
-		robotMax: make([]int, materialsCount),
+//
+// Any materials can be used, costs are separated by "and" or commas.
+func parseBlueprint(line string) (*blueprint, error) {
+	header, rest, found := strings.Cut(line, ":")
+	tokens := strings.Fields(header)
+	if !found || len(tokens) != 2 || tokens[0] != "Blueprint" {
+		return nil, errors.New("bad blueprint header: " + line)
 	}
+	id, err := strconv.Atoi(tokens[1])
+	if err != nil {
+		return nil, errors.New("bad blueprint id: " + line)
+	}
+	bp := &blueprint{id: id}
 
-	bp.robotCost[ore][ore] = input.MustAtoi(tokens[6])
-
-	bp.robotCost[clay][ore] = input.MustAtoi(tokens[12])
-
-	bp.robotCost[obsidian][ore] = input.MustAtoi(tokens[18])
-	bp.robotCost[obsidian][clay] = input.MustAtoi(tokens[21])
+	costs := map[material]map[material]int{}
+	for _, sentence := range strings.Split(rest, ".") {
+		tokens := strings.Fields(strings.ReplaceAll(sentence, ",", " "))
+		if len(tokens) == 0 {
+			continue
+		}
+		if len(tokens) < 4 || tokens[0] != "Each" || tokens[2] != "robot" || tokens[3] != "costs" {
+			return nil, fmt.Errorf("blueprint %d: bad robot: %q", id, strings.TrimSpace(sentence))
+		}
+		robot := bp.addMaterial(tokens[1])
+		if _, ok := costs[robot]; ok {
+			return nil, fmt.Errorf("blueprint %d: %s robot listed twice", id, tokens[1])
+		}
+		rc := map[material]int{}
+		costs[robot] = rc
+
+		tokens = tokens[4:]
+		for len(tokens) > 0 {
+			if tokens[0] == "and" {
+				tokens = tokens[1:]
+				continue
+			}
+			if len(tokens) < 2 {
+				return nil, fmt.Errorf("blueprint %d: bad cost: %q", id, strings.TrimSpace(sentence))
+			}
+			count, err := strconv.Atoi(tokens[0])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("blueprint %d: bad cost: %q", id, strings.TrimSpace(sentence))
+			}
+			rc[bp.addMaterial(tokens[1])] += count
+			tokens = tokens[2:]
+		}
+	}
+	if len(costs) == 0 {
+		return nil, fmt.Errorf("blueprint %d: no robots", id)
+	}
 
-	bp.robotCost[geode][ore] = input.MustAtoi(tokens[27])
-	bp.robotCost[geode][obsidian] = input.MustAtoi(tokens[30])
+	bp.robotCost = make([]robotCost, len(bp.materials))
+	for robot, rc := range costs {
+		bp.robotCost[robot] = make(robotCost, len(bp.materials))
+		for m, c := range rc {
+			bp.robotCost[robot][m] = c
+		}
+	}
 
-	for m := 0; m < materialsCount; m++ {
+	bp.robotMax = make([]int, len(bp.materials))
+	for m := range bp.materials {
 		bp.robotMax[m] = bp.maxRobotsForMaterial(material(m))
 	}
 
-	return bp
+	return bp, nil
 }
 
 func (b *blueprint) maxRobotsForMaterial(m material) int {
 	max := 0
 	for _, rc := range b.robotCost {
+		if rc == nil {
+			continue
+		}
 		mrc := rc[m]
 		if mrc > max {
 			max = mrc
@@ -223,87 +278,99 @@ func (b *blueprint) maxRobotsForMaterial(m material) int {
 	return max
 }
 
-func processFile(fileName string, mat material, timeToRun int, partOne bool) (int, error) {
-	// read all blueprints into world states
+// readStates reads all blueprints into world states.
+func readStates(fileName string) ([]*worldState, error) {
 	states := []*worldState{}
 	err := input.ReadFileLines(fileName, func(line string) error {
-		s := NewState(line)
+		s, err := NewState(line)
+		if err != nil {
+			return err
+		}
 		states = append(states, s)
 		return nil
 	})
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	return states, nil
+}
 
-	if partOne {
-		// run each state
-		var totalQ int
-		for _, s := range states {
-			start := time.Now()
-			fmt.Println("Starting at ", start)
-
-			fmt.Println(s.String())
-
-			max, _ := s.maxGoods(timeToRun, mat, 0)
-			fmt.Println("max: ", max)
+// processFile returns the quality level sum of the blueprints for part one,
+// the product of the first three maximums for part two, and the summary of
+// the blueprints.
+func processFile(fileName string, target string, timeToRun int, partOne bool) (int, string, error) {
+	states, err := readStates(fileName)
+	if err != nil {
+		return 0, "", err
+	}
 
-			totalQ += s.blueprint.id * max
-			fmt.Println("elapsed time: ", time.Since(start))
-			fmt.Println("---------------------------")
+	if !partOne {
+		// part two only has the first 3 blueprints left
+		states = states[:calc.Min(3, len(states))]
+	}
+	results, err := evaluate(states, target, timeToRun)
+	if err != nil {
+		return 0, "", err
+	}
+	for i, r := range results {
+		// the build order found must hold up on its own
+		if err = states[i].blueprint.verify(r.buildOrder(timeToRun), timeToRun, r.target, r.max); err != nil {
+			return 0, "", err
 		}
+	}
 
-		return totalQ, nil
+	if partOne {
+		var totalQ int
+		for _, r := range results {
+			totalQ += r.id * r.max
+		}
+		return totalQ, summary(results), nil
 	}
 
-	// part two
 	res := 1
-	for i := 0; i < 3; i++ {
-		s := states[i]
-
-		start := time.Now()
-		fmt.Println("Starting at ", start)
-
-		fmt.Println(s.String())
-
-		max, _ := s.maxGoods(timeToRun, mat, 0)
-		fmt.Println("max: ", max)
-
-		res *= max
-
-		fmt.Println("elapsed time: ", time.Since(start))
-		fmt.Println("---------------------------")
+	for _, r := range results {
+		res *= r.max
 	}
-
-	return res, nil
+	return res, summary(results), nil
 }
 
 func main() {
 	var res int
+	var sum string
 	var err error
 
-	res, err = processFile("data/part_one.txt", geode, 24, true)
+	res, sum, err = processFile("data/part_one.txt", "geode", 24, true)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
+	fmt.Print(sum)
 	fmt.Println(res)
 	fmt.Println("=================")
 	assert.Equals(33, res, "")
 
-	res, err = processFile("data/input.txt", geode, 24, true)
+	if err = explainFile("data/part_one.txt", "geode", 24, os.Stdout, false); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("=================")
+
+	res, sum, err = processFile("data/input.txt", "geode", 24, true)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
+	fmt.Print(sum)
 	fmt.Println(res)
 	fmt.Println("=================")
 	assert.Equals(1346, res, "")
 
-	res, err = processFile("data/input.txt", geode, 32, false)
+	res, sum, err = processFile("data/input.txt", "geode", 32, false)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
+	fmt.Print(sum)
 	fmt.Println(res)
 	fmt.Println("=================")
 	assert.Equals(7644, res, "")
@@ -1,17 +1,158 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// the materials of the puzzle blueprints, in the order they are listed
+const (
+	ore material = iota
+	clay
+	obsidian
+	geode
+)
+
+const exampleBlueprint = "Blueprint 1: Each ore robot costs 4 ore. Each clay robot costs 2 ore. Each obsidian robot costs 3 ore and 14 clay. Each geode robot costs 2 ore and 7 obsidian."
+
 func TestMain(t *testing.T) {
-	res, err := processFile("data/part_one.txt", geode, 24, true)
+	res, sum, err := processFile("data/part_one.txt", "geode", 24, true)
 	assert.Nil(t, err)
 	assert.Equal(t, 33, res)
+	assert.Equal(t, "blueprint 1: 9\nblueprint 2: 12\n", sum)
 
-	res, err = processFile("data/input.txt", geode, 32, false)
+	res, _, err = processFile("data/input.txt", "geode", 32, false)
 	assert.Nil(t, err)
 	assert.Equal(t, 7644, res)
 }
+
+func TestSeenKey(t *testing.T) {
+	// counts past a byte must not wrap onto smaller ones
+	assert.NotEqual(t, seenKey([]int{1, 256}, 3), seenKey([]int{1, 0}, 3))
+	assert.NotEqual(t, seenKey([]int{1, 2}, 259), seenKey([]int{1, 2}, 3))
+	assert.NotEqual(t, seenKey([]int{1, 2}, 3), seenKey([]int{1}, 2))
+	assert.Equal(t, seenKey([]int{300, 2}, 1000), seenKey([]int{300, 2}, 1000))
+}
+
+func TestEvaluateOrder(t *testing.T) {
+	states, err := readStates("data/input.txt")
+	assert.Nil(t, err)
+
+	results, err := evaluate(states, "geode", 24)
+	assert.Nil(t, err)
+	assert.Equal(t, len(states), len(results))
+	for i, r := range results {
+		assert.Equal(t, i+1, r.id)
+		max, _ := states[i].maxGoods(24, geode)
+		assert.Equal(t, max, r.max)
+	}
+}
+
+func BenchmarkPartTwo(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		processFile("data/input.txt", "geode", 32, false)
+	}
+}
+
+func TestBuildOrder(t *testing.T) {
+	ws, err := NewState(exampleBlueprint)
+	assert.Nil(t, err)
+	bp := ws.blueprint
+
+	// the build order from the puzzle description
+	order := buildOrder{
+		{3, clay}, {5, clay}, {7, clay}, {11, obsidian}, {12, clay},
+		{15, obsidian}, {18, geode}, {21, geode},
+	}
+	assert.Nil(t, bp.verify(order, 24, geode, 9))
+	assert.NotNil(t, bp.verify(order, 24, geode, 10))
+
+	minutes, err := bp.replay(order, 24)
+	assert.Nil(t, err)
+	assert.Equal(t, 24, len(minutes))
+	assert.Equal(t, clay, *minutes[2].built)
+	assert.Equal(t, []int{1, 1, 0, 0}, minutes[2].robots)
+	assert.Equal(t, []int{1, 0, 0, 0}, minutes[2].goods)
+	assert.Nil(t, minutes[3].built)
+
+	// not enough ore yet
+	assert.NotNil(t, bp.verify(buildOrder{{2, clay}}, 24, geode, 0))
+	// out of order
+	assert.NotNil(t, bp.verify(buildOrder{{5, clay}, {3, clay}}, 24, geode, 0))
+	// after the end
+	assert.NotNil(t, bp.verify(buildOrder{{25, clay}}, 24, geode, 0))
+
+	max, path := ws.maxGoods(24, geode)
+	r := blueprintResult{id: 1, max: max, path: path}
+	assert.Nil(t, bp.verify(r.buildOrder(24), 24, geode, 9))
+}
+
+func TestReport(t *testing.T) {
+	var text, js bytes.Buffer
+	assert.Nil(t, explainFile("data/part_one.txt", "geode", 24, &text, false))
+	assert.Nil(t, explainFile("data/part_one.txt", "geode", 24, &js, true))
+
+	assert.Contains(t, text.String(), "== Minute 24 ==")
+	assert.Contains(t, text.String(), "built geode robot")
+
+	dec := json.NewDecoder(&js)
+	for _, want := range []int{9, 12} {
+		var rep jsonReport
+		assert.Nil(t, dec.Decode(&rep))
+		assert.Equal(t, 24, len(rep.Minutes))
+		assert.Equal(t, want, rep.Minutes[23].Goods["geode"])
+	}
+}
+
+func TestParseBlueprint(t *testing.T) {
+	bp, err := parseBlueprint(exampleBlueprint)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ore", "clay", "obsidian", "geode"}, bp.materials)
+	assert.Equal(t, robotCost{3, 14, 0, 0}, bp.robotCost[obsidian])
+	assert.Equal(t, []int{4, 14, 7, 0}, bp.robotMax)
+
+	// materials only ever paid for have no robot
+	bp, err = parseBlueprint("Blueprint 7: Each sand robot costs 1 sand. Each glass robot costs 2 sand, 1 coal and 1 sand.")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sand", "glass", "coal"}, bp.materials)
+	assert.Equal(t, robotCost{3, 0, 1}, bp.robotCost[1])
+	assert.Nil(t, bp.robotCost[2])
+
+	for _, line := range []string{
+		"Blueprint x: Each ore robot costs 4 ore.",
+		"Each ore robot costs 4 ore.",
+		"Blueprint 1: Each ore robot costs four ore.",
+		"Blueprint 1: Each ore robot costs 4.",
+		"Blueprint 1: Each ore robot costs 4 ore. Each ore robot costs 2 ore.",
+		"Blueprint 1: Some ore robot costs 4 ore.",
+		"Blueprint 1:",
+	} {
+		_, err = parseBlueprint(line)
+		assert.NotNil(t, err, line)
+	}
+}
+
+func TestCustomMaterials(t *testing.T) {
+	// wood is needed for both the tools and the target
+	ws, err := NewState("Blueprint 1: Each wood robot costs 2 wood. Each tool robot costs 3 wood. Each chair robot costs 2 wood and 2 tool.")
+	assert.Nil(t, err)
+	chair, ok := ws.blueprint.material("chair")
+	assert.True(t, ok)
+
+	max, path := ws.maxGoods(12, chair)
+	r := blueprintResult{id: 1, target: chair, max: max, path: path}
+	assert.Nil(t, ws.blueprint.verify(r.buildOrder(12), 12, chair, max))
+	assert.Less(t, 0, max)
+
+	// any material can be the target
+	wood, _ := ws.blueprint.material("wood")
+	max, _ = ws.maxGoods(5, wood)
+	// a second wood robot only breaks even in 5 minutes
+	assert.Equal(t, 5, max)
+
+	_, err = evaluate([]*worldState{ws}, "geode", 24)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"kfet.org/aoc_common/calc"
+)
+
+// search is a depth first branch and bound over the robots to build next,
+// for a single blueprint.
+type search struct {
+	target material
+
+	best int
+	path []*nextState
+
+	// stack is the chain of states leading to the one being explored
+	stack []*nextState
+	// seen holds, per robots and time left, the goods of the states already
+	// explored. A state with no more of any good is dominated by one of them.
+	seen map[string][][]int
+}
+
+func newSearch(target material) *search {
+	return &search{
+		target: target,
+		best:   -1,
+		seen:   map[string][][]int{},
+	}
+}
+
+func seenKey(robots []int, timeLeft int) string {
+	// varints, so that no count is too big to tell apart
+	key := make([]byte, 0, len(robots)+1)
+	for _, r := range robots {
+		key = binary.AppendVarint(key, int64(r))
+	}
+	key = binary.AppendVarint(key, int64(timeLeft))
+	return string(key)
+}
+
+// usefulGoods caps the goods that can never be spent in the time left, so
+// that states differing only in surplus compare equal.
+func (s *search) usefulGoods(ns *nextState) []int {
+	ws := ns.ws
+	goods := make([]int, len(ws.goods))
+	for m, g := range ws.goods {
+		if material(m) != s.target && ns.timeLeft > 0 {
+			spendable := ns.timeLeft*ws.blueprint.robotMax[m] - ws.robots[m]*(ns.timeLeft-1)
+			g = calc.Min(g, calc.Max(spendable, 0))
+		}
+		goods[m] = g
+	}
+	return goods
+}
+
+// dominated records the state as seen, unless an explored state with the same
+// robots and time left had at least as much of every good.
+func (s *search) dominated(ns *nextState) bool {
+	key := seenKey(ns.ws.robots, ns.timeLeft)
+	goods := s.usefulGoods(ns)
+	for _, other := range s.seen[key] {
+		covers := true
+		for m, g := range goods {
+			if other[m] < g {
+				covers = false
+				break
+			}
+		}
+		if covers {
+			return true
+		}
+	}
+	s.seen[key] = append(s.seen[key], goods)
+	return false
+}
+
+func (s *search) explore(ns *nextState) {
+	s.stack = append(s.stack, ns)
+	defer func() { s.stack = s.stack[:len(s.stack)-1] }()
+
+	ws := ns.ws
+	if ns.timeLeft == 0 {
+		// at end of search, keep the goods produced
+		if g := ws.goods[s.target]; g > s.best {
+			s.best = g
+			s.path = append([]*nextState(nil), s.stack...)
+		}
+		return
+	}
+
+	if ws.upperLimitGoods(ns.timeLeft, s.target) <= s.best {
+		// theoretical maximum not better than what is already found
+		return
+	}
+	if s.dominated(ns) {
+		return
+	}
+
+	next := ws.nextStates(ns.timeLeft, s.target)
+	// the most advanced robots first, to raise the bound early
+	for i := len(next) - 1; i >= 0; i-- {
+		s.explore(next[i])
+	}
+}
+
+type blueprintResult struct {
+	id     int
+	target material
+	max    int
+	path   []*nextState
+}
+
+// evaluate runs the search for every state on a bounded pool of workers. The
+// results are in the order of the states.
+func evaluate(states []*worldState, targetName string, timeToRun int) ([]blueprintResult, error) {
+	results := make([]blueprintResult, len(states))
+	targets := make([]material, len(states))
+	for i, ws := range states {
+		target, ok := ws.blueprint.material(targetName)
+		if !ok {
+			return nil, fmt.Errorf("blueprint %d: unknown material %s", ws.blueprint.id, targetName)
+		}
+		targets[i] = target
+	}
+	tasks := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < calc.Min(runtime.NumCPU(), len(states)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				ws := states[i]
+				max, path := ws.maxGoods(timeToRun, targets[i])
+				results[i] = blueprintResult{
+					id:     ws.blueprint.id,
+					target: targets[i],
+					max:    max,
+					path:   path,
+				}
+			}
+		}()
+	}
+	for i := range states {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+
+	return results, nil
+}
+
+func summary(results []blueprintResult) string {
+	var sb strings.Builder
+	for _, r := range results {
+		sb.WriteString(fmt.Sprintf("blueprint %d: %d\n", r.id, r.max))
+	}
+	return sb.String()
+}
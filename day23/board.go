@@ -0,0 +1,278 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// board holds the positions of the elves.
+type board interface {
+	isPresent(p pos) bool
+	set(p pos)
+	unset(p pos)
+	// apply makes the moves of a round, all the elves leave before any
+	// arrives
+	apply(moves []proposal)
+	// elves returns the elves in reading order
+	elves() []pos
+	// bounds returns the corners of the smallest box holding all the elves,
+	// and their count
+	bounds() (pos, pos, int)
+}
+
+// bbox keeps the bounding box up to date as elves come and go, counting the
+// elves on every line of each axis.
+type bbox struct {
+	counts   [3]map[int]int
+	min, max pos
+	count    int
+}
+
+func newBBox() bbox {
+	return bbox{
+		counts: [3]map[int]int{{}, {}, {}},
+		min:    pos{math.MaxInt, math.MaxInt, math.MaxInt},
+		max:    pos{math.MinInt, math.MinInt, math.MinInt},
+	}
+}
+
+func (p pos) coords() [3]int {
+	return [3]int{p.x, p.y, p.z}
+}
+
+func fromCoords(c [3]int) pos {
+	return pos{c[0], c[1], c[2]}
+}
+
+func (b *bbox) add(p pos) {
+	b.count++
+	minc, maxc := b.min.coords(), b.max.coords()
+	for axis, c := range p.coords() {
+		b.counts[axis][c]++
+		if c < minc[axis] {
+			minc[axis] = c
+		}
+		if c > maxc[axis] {
+			maxc[axis] = c
+		}
+	}
+	b.min, b.max = fromCoords(minc), fromCoords(maxc)
+}
+
+func (b *bbox) remove(p pos) {
+	b.count--
+	if b.count == 0 {
+		*b = newBBox()
+		return
+	}
+	minc, maxc := b.min.coords(), b.max.coords()
+	for axis, c := range p.coords() {
+		counts := b.counts[axis]
+		counts[c]--
+		if counts[c] > 0 {
+			continue
+		}
+		delete(counts, c)
+		// the box shrinks when the last elf leaves its edge
+		for c == minc[axis] && counts[minc[axis]] == 0 {
+			minc[axis]++
+			c = minc[axis]
+		}
+		for c == maxc[axis] && counts[maxc[axis]] == 0 {
+			maxc[axis]--
+			c = maxc[axis]
+		}
+	}
+	b.min, b.max = fromCoords(minc), fromCoords(maxc)
+}
+
+// sparseBoard is a set of the positions.
+type sparseBoard struct {
+	m   map[pos]struct{}
+	box bbox
+}
+
+func NewSparseBoard() *sparseBoard {
+	return &sparseBoard{
+		m:   map[pos]struct{}{},
+		box: newBBox(),
+	}
+}
+
+func (b *sparseBoard) isPresent(p pos) bool {
+	_, ok := b.m[p]
+	return ok
+}
+
+func (b *sparseBoard) set(p pos) {
+	if _, ok := b.m[p]; ok {
+		return
+	}
+	b.m[p] = struct{}{}
+	b.box.add(p)
+}
+
+func (b *sparseBoard) unset(p pos) {
+	if _, ok := b.m[p]; !ok {
+		return
+	}
+	delete(b.m, p)
+	b.box.remove(p)
+}
+
+func (b *sparseBoard) apply(moves []proposal) {
+	for _, mv := range moves {
+		b.unset(mv.from)
+	}
+	for _, mv := range moves {
+		b.set(mv.to)
+	}
+}
+
+func (b *sparseBoard) elves() []pos {
+	res := make([]pos, 0, len(b.m))
+	for p := range b.m {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].less(res[j]) })
+	return res
+}
+
+func (b *sparseBoard) bounds() (pos, pos, int) {
+	return b.box.min, b.box.max, b.box.count
+}
+
+// denseBoard is a grid of bitset rows, one row per y and z, grown as the
+// elves spread. It is double buffered: a round is written to the back rows
+// while the front ones still hold the previous round, then the two swap.
+type denseBoard struct {
+	origin pos // position of the first bit of the first row
+	// size of the grid, the width is in 64 bit words
+	words, height, depth int
+	rows, back           []uint64
+	box                  bbox
+}
+
+func NewDenseBoard() *denseBoard {
+	return &denseBoard{box: newBBox()}
+}
+
+// index returns the word holding p and its bit, false outside the grid.
+func (b *denseBoard) index(p pos) (int, uint64, bool) {
+	x, y, z := p.x-b.origin.x, p.y-b.origin.y, p.z-b.origin.z
+	if x < 0 || y < 0 || z < 0 || x >= b.words*64 || y >= b.height || z >= b.depth {
+		return 0, 0, false
+	}
+	return (z*b.height+y)*b.words + x/64, 1 << (x % 64), true
+}
+
+func (b *denseBoard) isPresent(p pos) bool {
+	i, bit, ok := b.index(p)
+	return ok && b.rows[i]&bit != 0
+}
+
+// grow resizes the grid to hold p, with room to spare on all sides.
+func (b *denseBoard) grow(p pos) {
+	minp, maxp, _ := b.bounds()
+	if b.box.count == 0 {
+		minp, maxp = p, p
+	}
+	minc, maxc := minp.coords(), maxp.coords()
+	for axis, c := range p.coords() {
+		if c < minc[axis] {
+			minc[axis] = c
+		}
+		if c > maxc[axis] {
+			maxc[axis] = c
+		}
+	}
+
+	elves := b.elves()
+	margin := func(axis int) int {
+		if minc[axis] == maxc[axis] && axis == 2 {
+			// flat boards stay a single layer until they are not
+			return 0
+		}
+		return (maxc[axis]-minc[axis])/2 + 8
+	}
+	b.origin = pos{minc[0] - margin(0), minc[1] - margin(1), minc[2] - margin(2)}
+	b.words = (maxc[0]-minc[0]+2*margin(0))/64 + 1
+	b.height = maxc[1] - minc[1] + 2*margin(1) + 1
+	b.depth = maxc[2] - minc[2] + 2*margin(2) + 1
+	b.rows = make([]uint64, b.words*b.height*b.depth)
+	for _, e := range elves {
+		i, bit, _ := b.index(e)
+		b.rows[i] |= bit
+	}
+}
+
+func (b *denseBoard) set(p pos) {
+	i, bit, ok := b.index(p)
+	if !ok {
+		b.grow(p)
+		i, bit, _ = b.index(p)
+	}
+	if b.rows[i]&bit != 0 {
+		return
+	}
+	b.rows[i] |= bit
+	b.box.add(p)
+}
+
+func (b *denseBoard) unset(p pos) {
+	i, bit, ok := b.index(p)
+	if !ok || b.rows[i]&bit == 0 {
+		return
+	}
+	b.rows[i] &^= bit
+	b.box.remove(p)
+}
+
+func (b *denseBoard) apply(moves []proposal) {
+	// grow first, growing reallocates the rows
+	for _, mv := range moves {
+		if _, _, ok := b.index(mv.to); !ok {
+			b.grow(mv.to)
+		}
+	}
+	if len(b.back) != len(b.rows) {
+		b.back = make([]uint64, len(b.rows))
+	}
+	copy(b.back, b.rows)
+	for _, mv := range moves {
+		i, bit, _ := b.index(mv.from)
+		b.back[i] &^= bit
+		b.box.remove(mv.from)
+	}
+	for _, mv := range moves {
+		i, bit, _ := b.index(mv.to)
+		b.back[i] |= bit
+		b.box.add(mv.to)
+	}
+	b.rows, b.back = b.back, b.rows
+}
+
+func (b *denseBoard) elves() []pos {
+	res := make([]pos, 0, b.box.count)
+	if b.box.count == 0 {
+		return res
+	}
+	for z := b.box.min.z; z <= b.box.max.z; z++ {
+		for y := b.box.min.y; y <= b.box.max.y; y++ {
+			start, _, _ := b.index(pos{b.origin.x, y, z})
+			for w, word := range b.rows[start : start+b.words] {
+				for word != 0 {
+					x := w*64 + bits.TrailingZeros64(word)
+					res = append(res, pos{b.origin.x + x, y, z})
+					word &= word - 1
+				}
+			}
+		}
+	}
+	return res
+}
+
+func (b *denseBoard) bounds() (pos, pos, int) {
+	return b.box.min, b.box.max, b.box.count
+}
@@ -3,235 +3,244 @@ package main
 import (
 	"errors"
 	"fmt"
-	"math"
+	"runtime"
 	"strings"
+	"sync"
 
 	"kfet.org/aoc_common/assert"
+	"kfet.org/aoc_common/calc"
 	"kfet.org/aoc_common/input"
 )
 
-var noMoveRule = rule{
-	0, 0,
-	[]pos{
-		{-1, -1},
-		{0, -1},
-		{1, -1},
-		{-1, 0},
-		{1, 0},
-		{-1, 1},
-		{0, 1},
-		{1, 1},
-	},
-}
-var rulesCount int = len(rules)
-var rules []rule = []rule{
-	{ // N
-		0, -1,
-		[]pos{
-			{-1, -1},
-			{0, -1},
-			{1, -1},
-		},
-	},
-	{ // S
-		0, 1,
-		[]pos{
-			{-1, 1},
-			{0, 1},
-			{1, 1},
-		},
-	},
-	{ // W
-		-1, 0,
-		[]pos{
-			{-1, -1},
-			{-1, 0},
-			{-1, 1},
-		},
-	},
-	{ // E
-		1, 0,
-		[]pos{
-			{1, -1},
-			{1, 0},
-			{1, 1},
-		},
-	},
-}
-
 type rule struct {
-	dx, dy int
-	tests  []pos
+	name  string
+	move  pos
+	tests []pos
 }
 
 type field struct {
-	t int
-	m map[int]map[int]struct{}
+	t     int
+	b     board
+	rules *ruleSet
+	// workers compute the proposals in parallel, by bands of rows
+	workers int
+	history []roundStats
 }
 
-func NewField() *field {
+func NewField(rules *ruleSet, b board) *field {
 	return &field{
-		m: map[int]map[int]struct{}{},
+		b:       b,
+		rules:   rules,
+		workers: runtime.NumCPU(),
 	}
 }
 
 func (f *field) String() string {
 	var sb strings.Builder
 	minp, maxp, _ := f.findBoundaries()
-	for y := minp.y; y <= maxp.y; y++ {
-		for x := minp.x; x <= maxp.x; x++ {
-			if f.isPresent(x, y) {
-				sb.WriteRune('#')
-			} else {
-				sb.WriteRune('.')
+	for z := minp.z; z <= maxp.z; z++ {
+		if minp.z != maxp.z {
+			sb.WriteString(fmt.Sprintf("z=%d\n", z))
+		}
+		for y := minp.y; y <= maxp.y; y++ {
+			for x := minp.x; x <= maxp.x; x++ {
+				if f.isPresent(pos{x, y, z}) {
+					sb.WriteRune('#')
+				} else {
+					sb.WriteRune('.')
+				}
 			}
+			sb.WriteRune('\n')
 		}
-		sb.WriteRune('\n')
 	}
 	return sb.String()
 }
 
 func (f *field) findBoundaries() (pos, pos, int) {
-	minX, minY := math.MaxInt, math.MaxInt
-	maxX, maxY := math.MinInt, math.MinInt
-	count := 0
-	for y, row := range f.m {
-		for x := range row {
-			count++
-			if x < minX {
-				minX = x
-			}
-			if x > maxX {
-				maxX = x
-			}
-			if y < minY {
-				minY = y
-			}
-			if y > maxY {
-				maxY = y
-			}
-		}
-	}
+	return f.b.bounds()
+}
 
-	return pos{minX, minY}, pos{maxX, maxY}, count
+// emptyTiles counts the free tiles in the smallest box holding all the elves.
+func (f *field) emptyTiles() int {
+	_, _, count := f.findBoundaries()
+	return f.area() - count
 }
 
-func (f *field) testRule(x, y int, r rule) bool {
-	for _, t := range r.tests {
-		if f.isPresent(x+t.x, y+t.y) {
+func (f *field) testRule(p pos, tests []pos) bool {
+	for _, t := range tests {
+		if f.isPresent(p.add(t)) {
 			return false
 		}
 	}
 	return true
 }
 
-func (f *field) tick() bool {
-	// compile proposed moves
-	pm := NewProposedMoves()
-	for y, row := range f.m {
-		for x := range row {
-			// try no-move rule first
-			if f.testRule(x, y, noMoveRule) {
-				// stay put, no-move-rule matches
-				continue
-			}
-
-			for i := 0; i < rulesCount; i++ {
-				// try each rule
-				r := rules[(f.t+i)%rulesCount]
-				if !f.testRule(x, y, r) {
-					// can't apply rule, try the next one
-					continue
-				}
-				// rule matches
-				pm.propose(x, y, r)
-				break // .. from rules loop
-			}
+// bands splits the elves, in reading order, into at most n bands of whole
+// rows.
+func bands(elves []pos, n int) [][]pos {
+	res := [][]pos{}
+	size := (len(elves) + n - 1) / n
+	for start := 0; start < len(elves); {
+		end := calc.Min(start+size, len(elves))
+		for end < len(elves) && elves[end].y == elves[end-1].y && elves[end].z == elves[end-1].z {
+			end++
 		}
+		res = append(res, elves[start:end])
+		start = end
 	}
+	return res
+}
 
-	// apply proposed moves
-	anyMove := false
-	for to, from := range pm.toFrom {
-		if _, ok := pm.discarded[to]; ok {
-			// skip discarded
+type proposal struct {
+	from, to pos
+}
+
+// propose returns the moves the elves want to make, in their order. The
+// board is only read.
+func (f *field) propose(elves []pos) []proposal {
+	res := []proposal{}
+	for _, p := range elves {
+		// try no-move rule first
+		if f.testRule(p, f.rules.neighbours) {
+			// stay put, no-move-rule matches
 			continue
 		}
-		f.move(from, to)
-		anyMove = true
-	}
 
-	f.t++
-	return anyMove
+		for i := range f.rules.rules {
+			// try each rule
+			r := f.rules.ruleAt(f.t, i)
+			if !f.testRule(p, r.tests) {
+				// can't apply rule, try the next one
+				continue
+			}
+			// rule matches
+			res = append(res, proposal{p, p.add(r.move)})
+			break // .. from rules loop
+		}
+	}
+	return res
 }
 
-func (f *field) isPresent(x, y int) bool {
-	if _, ok := f.m[y]; !ok {
-		return false
+// minBandSize keeps small fields from paying for the goroutines.
+const minBandSize = 256
+
+func (f *field) tick() bool {
+	// compile proposed moves, band by band
+	elves := f.b.elves()
+	workers := calc.Max(1, calc.Min(f.workers, len(elves)/minBandSize))
+	bs := bands(elves, workers)
+	results := make([][]proposal, len(bs))
+	var wg sync.WaitGroup
+	for i, band := range bs {
+		wg.Add(1)
+		go func(i int, band []pos) {
+			defer wg.Done()
+			results[i] = f.propose(band)
+		}(i, band)
 	}
-	if _, ok := f.m[y][x]; !ok {
-		return false
+	wg.Wait()
+
+	// merge in band order, so the collisions are settled as if proposed one
+	// by one in reading order
+	pm := NewProposedMoves(f.rules.collision)
+	for _, res := range results {
+		for _, pr := range res {
+			pm.propose(pr.from, pr.to)
+		}
 	}
-	return true
+
+	moves := pm.moves()
+	f.b.apply(moves)
+
+	f.t++
+	f.history = append(f.history, f.roundStats(len(moves), pm.rejected))
+	return len(moves) > 0
 }
 
-func (f *field) set(p pos) {
-	if _, ok := f.m[p.y]; !ok {
-		f.m[p.y] = map[int]struct{}{}
-	}
-	f.m[p.y][p.x] = struct{}{}
+func (f *field) isPresent(p pos) bool {
+	return f.b.isPresent(p)
 }
 
-func (f *field) unset(p pos) {
-	delete(f.m[p.y], p.x)
+// pos is 2D for the puzzle, z stays 0
+type pos struct {
+	x, y, z int
 }
 
-func (f *field) move(from, to pos) {
-	f.unset(from)
-	f.set(to)
+func (p pos) add(o pos) pos {
+	return pos{p.x + o.x, p.y + o.y, p.z + o.z}
 }
 
-type pos struct {
-	x, y int
+// less is the reading order: by z, then y, then x.
+func (p pos) less(o pos) bool {
+	if p.z != o.z {
+		return p.z < o.z
+	}
+	if p.y != o.y {
+		return p.y < o.y
+	}
+	return p.x < o.x
 }
 
 type proposedMoves struct {
+	policy    collisionPolicy
 	toFrom    map[pos]pos
+	order     []pos // targets in the order first proposed
 	discarded map[pos]struct{}
+	rejected  int // proposals not standing because of a collision
 }
 
-func NewProposedMoves() *proposedMoves {
+func NewProposedMoves(policy collisionPolicy) *proposedMoves {
 	return &proposedMoves{
+		policy:    policy,
 		toFrom:    map[pos]pos{},
 		discarded: map[pos]struct{}{},
 	}
 }
 
-func (pm *proposedMoves) propose(x, y int, r rule) bool {
-	to := pos{x + r.dx, y + r.dy}
+func (pm *proposedMoves) propose(from, to pos) bool {
 	if _, ok := pm.discarded[to]; ok {
+		pm.rejected++
 		return false
 	}
 
 	if _, ok := pm.toFrom[to]; ok {
-		pm.discarded[to] = struct{}{}
+		pm.rejected++
+		if pm.policy == discardAll {
+			// the earlier proposal goes too
+			pm.discarded[to] = struct{}{}
+			pm.rejected++
+		}
+		// with firstWins the earlier proposal stands
 		return false
 	}
 
-	pm.toFrom[to] = pos{x, y}
+	pm.toFrom[to] = from
+	pm.order = append(pm.order, to)
 	return true
 }
 
-func processFile(fileName string, partOne bool) (int, error) {
+// moves returns the moves that stand, in the order proposed.
+func (pm *proposedMoves) moves() []proposal {
+	res := []proposal{}
+	for _, to := range pm.order {
+		if _, ok := pm.discarded[to]; ok {
+			// skip discarded
+			continue
+		}
+		res = append(res, proposal{pm.toFrom[to], to})
+	}
+	return res
+}
 
-	f := NewField()
+func readField(fileName string, rules *ruleSet, b board) (*field, error) {
+	f := NewField(rules, b)
 
 	var row int
 	err := input.ReadFileLines(fileName, func(line string) error {
 		for x, r := range line {
 			switch r {
 			case '#':
-				f.set(pos{x, row})
+				f.b.set(pos{x, row, 0})
 			case '.':
 			default:
 				return errors.New(fmt.Sprint("wrong character in map ", r))
@@ -240,6 +249,18 @@ func processFile(fileName string, partOne bool) (int, error) {
 		row++
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func processFile(fileName string, partOne bool) (int, error) {
+	return processFileRules(fileName, defaultRuleSet(), NewDenseBoard(), partOne)
+}
+
+func processFileRules(fileName string, rules *ruleSet, b board, partOne bool) (int, error) {
+	f, err := readField(fileName, rules, b)
 	if err != nil {
 		return 0, err
 	}
@@ -253,8 +274,7 @@ func processFile(fileName string, partOne bool) (int, error) {
 			f.tick()
 		}
 
-		minp, maxp, count := f.findBoundaries()
-		res = (maxp.x-minp.x+1)*(maxp.y-minp.y+1) - count
+		res = f.emptyTiles()
 	} else {
 		for i := 0; ; i++ {
 			if !f.tick() {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,3 +13,210 @@ func TestPartOneSmall(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 25, res)
 }
+
+func TestParts(t *testing.T) {
+	for _, tc := range []struct {
+		fileName string
+		partOne  bool
+		want     int
+	}{
+		{"data/part_one.txt", true, 110},
+		{"data/input.txt", true, 4082},
+		{"data/part_one_small.txt", false, 4},
+		{"data/part_one.txt", false, 20},
+		{"data/input.txt", false, 1065},
+	} {
+		res, err := processFile(tc.fileName, tc.partOne)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, res, tc.fileName)
+	}
+}
+
+func TestRoundStats(t *testing.T) {
+	f, err := readField("data/part_one_small.txt", defaultRuleSet(), NewDenseBoard())
+	assert.Nil(t, err)
+	for f.tick() {
+	}
+
+	// the puzzle example: the elves at 2,2 and 2,4 collide in the first round
+	assert.Equal(t, []roundStats{
+		{round: 1, moved: 3, discarded: 2, area: 10, empty: 5},
+		{round: 2, moved: 5, discarded: 0, area: 20, empty: 15},
+		{round: 3, moved: 3, discarded: 0, area: 30, empty: 25},
+		{round: 4, moved: 0, discarded: 0, area: 30, empty: 25},
+	}, f.rounds())
+
+	var buf bytes.Buffer
+	assert.Nil(t, f.writeCSV(&buf))
+	assert.Equal(t, "round,moved,discarded,area,empty\n"+
+		"1,3,2,10,5\n2,5,0,20,15\n3,3,0,30,25\n4,0,0,30,25\n", buf.String())
+}
+
+func TestRoundStatsInput(t *testing.T) {
+	f, err := readField("data/input.txt", defaultRuleSet(), NewDenseBoard())
+	assert.Nil(t, err)
+	for f.tick() {
+	}
+
+	rounds := f.rounds()
+	assert.Equal(t, 1065, len(rounds))
+	assert.Equal(t, 4082, rounds[9].empty)
+	for i, rs := range rounds {
+		assert.Equal(t, i+1, rs.round)
+		assert.Equal(t, rs.moved == 0, i == len(rounds)-1)
+	}
+}
+
+func TestReadRuleSet(t *testing.T) {
+	rs := defaultRuleSet()
+	assert.Equal(t, 8, len(rs.neighbours))
+	assert.Equal(t, 4, len(rs.rules))
+	assert.Equal(t, "W", rs.ruleAt(1, 1).name)
+	assert.True(t, rs.rotate)
+	assert.Equal(t, discardAll, rs.collision)
+
+	rs, err := readRuleSetFile("data/rules_3d.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 26, len(rs.neighbours))
+	assert.Equal(t, pos{0, 0, 1}, rs.rules[5].move)
+
+	for _, text := range []string{
+		"",
+		"rule N 0,-1 -1,-1",
+		"rule N 0 : -1,-1",
+		"rule N 0,-1 : a,b",
+		"rule N 0,-1 : 0,-1\nrotate maybe",
+		"rule N 0,-1 : 0,-1\ncollision bounce",
+		"rule N 0,-1 : 0,-1\njump",
+	} {
+		_, err := readRuleSet(strings.NewReader(text))
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestRuleOrder(t *testing.T) {
+	// without rotation, the elves keep going north first
+	rs, err := readRuleSet(strings.NewReader(strings.Replace(defaultRules, "rotate true", "rotate false", 1)))
+	assert.Nil(t, err)
+	assert.Equal(t, "N", rs.ruleAt(3, 0).name)
+
+	f, err := readField("data/part_one_small.txt", rs, NewDenseBoard())
+	assert.Nil(t, err)
+	f.tick()
+	f.tick()
+	assert.Equal(t, "##.\n...\n#..\n...\n..#\n...\n#..\n", f.String())
+}
+
+func TestCollisionPolicy(t *testing.T) {
+	rs := defaultRuleSet()
+	rs.collision = firstWins
+	f, err := readField("data/part_one_small.txt", rs, NewDenseBoard())
+	assert.Nil(t, err)
+
+	// the elves at 2,2 and 2,4 both propose 2,3, the first one gets it
+	f.tick()
+	assert.True(t, f.isPresent(pos{2, 3, 0}))
+	assert.False(t, f.isPresent(pos{2, 2, 0}))
+	assert.True(t, f.isPresent(pos{2, 4, 0}))
+	_, _, count := f.findBoundaries()
+	assert.Equal(t, 5, count)
+}
+
+func TestVariants(t *testing.T) {
+	for _, rulesFile := range []string{"data/rules_hex.txt", "data/rules_3d.txt"} {
+		rs, err := readRuleSetFile(rulesFile)
+		assert.Nil(t, err)
+
+		res, err := processFileRules("data/part_one.txt", rs, NewDenseBoard(), false)
+		assert.Nil(t, err)
+		again, err := processFileRules("data/part_one.txt", rs, NewSparseBoard(), false)
+		assert.Nil(t, err)
+		assert.Equal(t, res, again, rulesFile)
+		assert.Less(t, 0, res, rulesFile)
+	}
+}
+
+func TestBoards(t *testing.T) {
+	for _, tc := range []struct {
+		fileName string
+		partOne  bool
+		want     int
+	}{
+		{"data/part_one.txt", true, 110},
+		{"data/part_one.txt", false, 20},
+		{"data/input.txt", true, 4082},
+	} {
+		for _, b := range []board{NewSparseBoard(), NewDenseBoard()} {
+			res, err := processFileRules(tc.fileName, defaultRuleSet(), b, tc.partOne)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, res, tc.fileName)
+		}
+	}
+}
+
+func TestBBox(t *testing.T) {
+	b := NewDenseBoard()
+	b.set(pos{0, 0, 0})
+	b.set(pos{100, -3, 0})
+	b.set(pos{5, 7, 0})
+	minp, maxp, count := b.bounds()
+	assert.Equal(t, pos{0, -3, 0}, minp)
+	assert.Equal(t, pos{100, 7, 0}, maxp)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, []pos{{100, -3, 0}, {0, 0, 0}, {5, 7, 0}}, b.elves())
+
+	b.unset(pos{100, -3, 0})
+	minp, maxp, count = b.bounds()
+	assert.Equal(t, pos{0, 0, 0}, minp)
+	assert.Equal(t, pos{5, 7, 0}, maxp)
+	assert.Equal(t, 2, count)
+	assert.False(t, b.isPresent(pos{100, -3, 0}))
+	assert.True(t, b.isPresent(pos{5, 7, 0}))
+}
+
+func TestApply(t *testing.T) {
+	for _, b := range []board{NewSparseBoard(), NewDenseBoard()} {
+		b.set(pos{0, 0, 0})
+		b.set(pos{1, 0, 0})
+		// a chain of moves, and one far off the board
+		b.apply([]proposal{
+			{pos{0, 0, 0}, pos{1, 0, 0}},
+			{pos{1, 0, 0}, pos{500, 2, 0}},
+		})
+		assert.Equal(t, []pos{{1, 0, 0}, {500, 2, 0}}, b.elves())
+		assert.False(t, b.isPresent(pos{0, 0, 0}))
+		minp, maxp, count := b.bounds()
+		assert.Equal(t, pos{1, 0, 0}, minp)
+		assert.Equal(t, pos{500, 2, 0}, maxp)
+		assert.Equal(t, 2, count)
+	}
+}
+
+func TestBands(t *testing.T) {
+	elves := []pos{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}, {0, 1, 0}, {0, 2, 0}}
+	bs := bands(elves, 3)
+	// rows are never split
+	assert.Equal(t, [][]pos{elves[0:3], elves[3:5]}, bs)
+}
+
+func BenchmarkPartTwo(b *testing.B) {
+	for _, bc := range []struct {
+		name     string
+		newBoard func() board
+	}{
+		{"sparse", func() board { return NewSparseBoard() }},
+		{"dense", func() board { return NewDenseBoard() }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				// read the field in the loop, the ticks change it, but do
+				// not go through processFileRules, which prints it
+				f, err := readField("data/input.txt", defaultRuleSet(), bc.newBoard())
+				assert.Nil(b, err)
+				for f.tick() {
+				}
+				assert.Equal(b, 1065, f.t)
+			}
+		})
+	}
+}
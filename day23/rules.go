@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"kfet.org/aoc_common/input"
+)
+
+// collisionPolicy decides what happens when several elves propose the same
+// spot.
+type collisionPolicy uint8
+
+const (
+	// discardAll keeps all the elves proposing the spot in place
+	discardAll collisionPolicy = iota
+	// firstWins moves the first elf proposing the spot, in reading order
+	firstWins
+)
+
+var collisionPolicies = map[string]collisionPolicy{
+	"discard": discardAll,
+	"first":   firstWins,
+}
+
+// ruleSet is a variant of the diffusion: an elf with no other elf among its
+// neighbours stays put, otherwise it proposes the move of the first rule
+// with all its tests free.
+type ruleSet struct {
+	neighbours []pos
+	rules      []rule
+	// rotate starts each round with the rule after the one the previous
+	// round started with
+	rotate    bool
+	collision collisionPolicy
+}
+
+// defaultRules is the puzzle: N, S, W, E, rotating, discarding collisions.
+const defaultRules = `neighbours -1,-1 0,-1 1,-1 -1,0 1,0 -1,1 0,1 1,1
+rule N 0,-1 : -1,-1 0,-1 1,-1
+rule S 0,1 : -1,1 0,1 1,1
+rule W -1,0 : -1,-1 -1,0 -1,1
+rule E 1,0 : 1,-1 1,0 1,1
+rotate true
+collision discard
+`
+
+func defaultRuleSet() *ruleSet {
+	rs, err := readRuleSet(strings.NewReader(defaultRules))
+	if err != nil {
+		panic(err)
+	}
+	return rs
+}
+
+func parseOffset(s string) (pos, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return pos{}, errors.New("wrong offset " + s)
+	}
+	var coords [3]int
+	for i, part := range parts {
+		c, err := strconv.Atoi(part)
+		if err != nil {
+			return pos{}, errors.New("wrong offset " + s)
+		}
+		coords[i] = c
+	}
+	return pos{coords[0], coords[1], coords[2]}, nil
+}
+
+func parseOffsets(fields []string) ([]pos, error) {
+	res := []pos{}
+	for _, f := range fields {
+		p, err := parseOffset(f)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// readRuleSet reads a rule set, one directive per line, '#' starting a
+// comment:
+//
+//	neighbours <offset>...
+//	rule <name> <move> : <offset>...
+//	rotate true|false
+//	collision discard|first
+//
+// Offsets are "x,y" or "x,y,z". The rules are tried in the order listed.
+// Without neighbours, the ones tested by any rule are used.
+func readRuleSet(r io.Reader) (*ruleSet, error) {
+	rs := &ruleSet{}
+	var lineNum int
+	err := input.ReadLines(r, func(line string) error {
+		lineNum++
+		if i := strings.IndexRune(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return nil
+		}
+		fail := func(err error) error {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		switch fields[0] {
+		case "neighbours":
+			ns, err := parseOffsets(fields[1:])
+			if err != nil {
+				return fail(err)
+			}
+			rs.neighbours = ns
+
+		case "rule":
+			if len(fields) < 5 || fields[3] != ":" {
+				return fail(errors.New("wrong rule " + line))
+			}
+			move, err := parseOffset(fields[2])
+			if err != nil {
+				return fail(err)
+			}
+			tests, err := parseOffsets(fields[4:])
+			if err != nil {
+				return fail(err)
+			}
+			rs.rules = append(rs.rules, rule{
+				name:  fields[1],
+				move:  move,
+				tests: tests,
+			})
+
+		case "rotate":
+			if len(fields) != 2 {
+				return fail(errors.New("wrong rotate " + line))
+			}
+			rotate, err := strconv.ParseBool(fields[1])
+			if err != nil {
+				return fail(err)
+			}
+			rs.rotate = rotate
+
+		case "collision":
+			if len(fields) != 2 {
+				return fail(errors.New("wrong collision " + line))
+			}
+			policy, ok := collisionPolicies[fields[1]]
+			if !ok {
+				return fail(errors.New("unknown collision policy " + fields[1]))
+			}
+			rs.collision = policy
+
+		default:
+			return fail(errors.New("unknown directive " + fields[0]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rs.rules) == 0 {
+		return nil, errors.New("no rules")
+	}
+	if rs.neighbours == nil {
+		seen := map[pos]struct{}{}
+		for _, r := range rs.rules {
+			for _, t := range r.tests {
+				if _, ok := seen[t]; !ok {
+					seen[t] = struct{}{}
+					rs.neighbours = append(rs.neighbours, t)
+				}
+			}
+		}
+	}
+	return rs, nil
+}
+
+func readRuleSetFile(fileName string) (*ruleSet, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readRuleSet(file)
+}
+
+// ruleAt returns the i-th rule tried in the round.
+func (rs *ruleSet) ruleAt(round, i int) rule {
+	if rs.rotate {
+		i += round
+	}
+	return rs.rules[i%len(rs.rules)]
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// roundStats records a round of the diffusion.
+type roundStats struct {
+	round int // counting from 1
+	moved int
+	// discarded are the elves that proposed a move but collided
+	discarded int
+	// area of the bounding box after the round, a volume in 3D
+	area  int
+	empty int
+}
+
+// area of the bounding box, a volume in 3D.
+func (f *field) area() int {
+	minp, maxp, count := f.findBoundaries()
+	if count == 0 {
+		return 0
+	}
+	return (maxp.x - minp.x + 1) * (maxp.y - minp.y + 1) * (maxp.z - minp.z + 1)
+}
+
+func (f *field) roundStats(moved, discarded int) roundStats {
+	return roundStats{
+		round:     f.t,
+		moved:     moved,
+		discarded: discarded,
+		area:      f.area(),
+		empty:     f.emptyTiles(),
+	}
+}
+
+// rounds returns the record of every round run so far.
+func (f *field) rounds() []roundStats {
+	return f.history
+}
+
+func (f *field) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"round", "moved", "discarded", "area", "empty"})
+	if err != nil {
+		return err
+	}
+	for _, rs := range f.history {
+		err = cw.Write([]string{
+			strconv.Itoa(rs.round),
+			strconv.Itoa(rs.moved),
+			strconv.Itoa(rs.discarded),
+			strconv.Itoa(rs.area),
+			strconv.Itoa(rs.empty),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
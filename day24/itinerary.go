@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// isWaypoint tells if the expedition can head to g: a spot inside the valley,
+// the start or the exit.
+func (f *field) isWaypoint(g goal) bool {
+	if f.isEdge(g) {
+		return true
+	}
+	return g.x >= 0 && g.x < f.w && g.y >= 0 && g.y < f.h
+}
+
+// path returns the states from the start up to fs, one per minute.
+func (fs *fieldState) path() []*fieldState {
+	res := []*fieldState{}
+	for s := fs; s != nil; s = s.parent {
+		res = append(res, s)
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
+
+// findPath searches the quickest trip from the start through the waypoints,
+// in order, and returns it minute by minute.
+func (f *field) findPath(waypoints []goal) ([]*fieldState, error) {
+	if len(waypoints) == 0 {
+		return nil, errors.New("no waypoints")
+	}
+	for _, g := range waypoints {
+		if !f.isWaypoint(g) {
+			return nil, errors.New(fmt.Sprint("waypoint outside of the valley ", g))
+		}
+	}
+
+	fs := NewFieldState(f, waypoints[0])
+	res, found := NewSearch(f).minPathGoals(fs, math.MaxInt, waypoints)
+	if !found {
+		return nil, errors.New("path not found")
+	}
+	return res.path(), nil
+}
+
+// processItinerary reads the field and finds the path through the waypoints.
+func processItinerary(fileName string, waypoints []goal, precompute bool) ([]*fieldState, error) {
+	f, err := readField(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if precompute {
+		f.precompute()
+	}
+	return f.findPath(waypoints)
+}
+
+// writeFrames dumps the field every minute of the path, as an animation.
+func writeFrames(w io.Writer, path []*fieldState) error {
+	for _, fs := range path {
+		_, err := fmt.Fprintf(w, "== Minute %d ==\n%s\n", fs.exp.t, fs.f.String(fs.exp, fs.dest))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
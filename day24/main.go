@@ -4,7 +4,6 @@ import (
 	"container/heap"
 	"errors"
 	"fmt"
-	"math"
 	"strings"
 
 	"github.com/samber/lo"
@@ -42,6 +41,10 @@ type field struct {
 	rbr  []*rightBlizzardRing // '>' rings
 	ubr  []*blizzardRing      // '^' rings
 	dbr  []*rightBlizzardRing // 'v' rings
+
+	// occupancy holds, once precomputed, a bitset row per time index and y
+	occupancy []uint64
+	words     int // words per row
 }
 
 func (f *field) String(exp pos, dest goal) string {
@@ -148,7 +151,36 @@ func (f *field) readMatrix(matrix []string) error {
 	return nil
 }
 
+// precompute fills the occupancy of every spot of the field for all the
+// time indexes, so that hasBlizzard is a single bit test.
+func (f *field) precompute() {
+	f.words = (f.w + 63) / 64
+	f.occupancy = make([]uint64, f.lcd*f.h*f.words)
+	for t := 0; t < f.lcd; t++ {
+		for y := 0; y < f.h; y++ {
+			row := f.occupancyRow(t, y)
+			for x := 0; x < f.w; x++ {
+				if f.ringsHaveBlizzard(pos{x, y, t}) {
+					row[x/64] |= 1 << (x % 64)
+				}
+			}
+		}
+	}
+}
+
+func (f *field) occupancyRow(timeIndex, y int) []uint64 {
+	start := (timeIndex*f.h + y) * f.words
+	return f.occupancy[start : start+f.words]
+}
+
 func (f *field) hasBlizzard(p pos) bool {
+	if f.occupancy != nil {
+		return f.occupancyRow(p.t%f.lcd, p.y)[p.x/64]&(1<<(p.x%64)) != 0
+	}
+	return f.ringsHaveBlizzard(p)
+}
+
+func (f *field) ringsHaveBlizzard(p pos) bool {
 	return f.lbr[p.y].hasBlizzard(p.x, p.t) ||
 		f.rbr[p.y].hasBlizzard(p.x, p.t) ||
 		f.ubr[p.x].hasBlizzard(p.y, p.t) ||
@@ -160,6 +192,10 @@ type fieldState struct {
 	dist      int // distance to goal - calculated after position is determined
 	timeIndex int // type modulus the world loop length, i.e. field state repeat
 	f         *field
+
+	dest   goal        // goal the expedition is heading to
+	leg    int         // index of dest among the goals of the search
+	parent *fieldState // state a minute earlier, nil at the start
 }
 
 func (fs *fieldState) String(dest goal) string {
@@ -183,6 +219,7 @@ func NewFieldState(f *field, dest goal) *fieldState {
 		exp:  exp,
 		dist: exp.calcDist(dest),
 		f:    f,
+		dest: dest,
 	}
 }
 
@@ -198,25 +235,84 @@ func (fs *fieldState) copy(dp pos, dest goal) *fieldState {
 		dist:      exp.calcDist(dest),
 		timeIndex: exp.t % fs.f.lcd,
 		f:         fs.f,
+		dest:      dest,
+		leg:       fs.leg,
+		parent:    fs,
 	}
 }
 
 type timeSpace struct {
-	x, y, timeIndex int
+	leg, x, y, timeIndex int
 }
 
-// Here we track visited positions to deted loop
-var visited = map[timeSpace]struct{}{}
+// search holds the state of a single path search, so that any number of
+// searches can run at the same time.
+type search struct {
+	f *field
+
+	// Here we track visited positions to deted loop
+	visited map[timeSpace]struct{}
+	// with the occupancy precomputed, the visited positions are a bitset
+	// laid out the same way, with a row more above and below the field for
+	// the start and exit
+	seen []uint64
+}
 
-func (fs *fieldState) allowed() bool {
+func NewSearch(f *field) *search {
+	s := &search{f: f}
+	s.reset(1)
+	return s
+}
+
+// reset forgets the visited positions, for a search through legs goals.
+func (s *search) reset(legs int) {
+	if s.f.occupancy == nil {
+		s.visited = map[timeSpace]struct{}{}
+		return
+	}
+	size := legs * s.f.lcd * (s.f.h + 2) * s.f.words
+	if len(s.seen) != size {
+		s.seen = make([]uint64, size)
+		return
+	}
+	for i := range s.seen {
+		s.seen[i] = 0
+	}
+}
+
+// visit marks the position as visited, false if it already was.
+func (s *search) visit(fs *fieldState) bool {
+	if s.seen != nil {
+		x, y := fs.exp.x, fs.exp.y+1
+		if x < 0 || x >= s.f.w || y < 0 || y >= s.f.h+2 {
+			// outside of bounds, allowed tells
+			return true
+		}
+		i := ((fs.leg*s.f.lcd+fs.timeIndex)*(s.f.h+2)+y)*s.f.words + x/64
+		bit := uint64(1) << (x % 64)
+		if s.seen[i]&bit != 0 {
+			// loop detected
+			return false
+		}
+		s.seen[i] |= bit
+		return true
+	}
 
-	ts := timeSpace{fs.exp.x, fs.exp.y, fs.timeIndex}
-	if _, ok := visited[ts]; ok {
+	ts := timeSpace{fs.leg, fs.exp.x, fs.exp.y, fs.timeIndex}
+	if _, ok := s.visited[ts]; ok {
 		// loop detected
 		return false
 	}
-	visited[ts] = struct{}{}
+	s.visited[ts] = struct{}{}
+	return true
+}
+
+// isEdge tells if g is the start or the exit, out of the blizzards' way.
+func (f *field) isEdge(g goal) bool {
+	return g.x == f.s && g.y == -1 || g.x == f.e && g.y == f.h
+}
 
+func (fs *fieldState) allowed() bool {
 	// Allow initial states
 	if fs.exp.x == fs.f.s && fs.exp.y == -1 {
 		return true
@@ -249,7 +345,7 @@ func (p pos) String() string {
 	return fmt.Sprint(p.x, p.y, p.t)
 }
 
-func (fs *fieldState) nextStates(dest goal) []*fieldState {
+func (s *search) nextStates(fs *fieldState, dest goal) []*fieldState {
 	return lo.FilterMap([]pos{
 		{0, 0, 1},  // stay put
 		{-1, 0, 1}, // left
@@ -258,7 +354,7 @@ func (fs *fieldState) nextStates(dest goal) []*fieldState {
 		{0, +1, 1}, // down
 	}, func(item pos, index int) (*fieldState, bool) {
 		res := fs.copy(item, dest)
-		return res, res.allowed()
+		return res, s.visit(res) && res.allowed()
 	})
 }
 
@@ -282,24 +378,15 @@ func (fh *fsHeap) pushAll(fss []*fieldState) {
 	}
 }
 
-func (fs *fieldState) minPathGoals(maxTime int, goals []goal) (*fieldState, bool) {
-	nfs := fs
-	for _, g := range goals {
-		// reset visited map for each new goal
-		visited = map[timeSpace]struct{}{}
-		nfs.dist = nfs.exp.calcDist(g)
-
-		var found bool
-		nfs, found = nfs.minPathBFS(maxTime, g)
-		if !found {
-			return nil, false
-		}
-	}
-
-	return nfs, true
-}
-
-func (fs *fieldState) minPathBFS(maxTime int, dest goal) (*fieldState, bool) {
+// minPathGoals searches the quickest trip through the goals, in order. The
+// search runs over the goals and the positions together, minute by minute:
+// the earliest arrival at a goal inside the valley can be a dead end, so
+// every state reaching a goal carries on to the next one.
+func (s *search) minPathGoals(fs *fieldState, maxTime int, goals []goal) (*fieldState, bool) {
+	s.reset(len(goals))
+	fs.leg = 0
+	fs.dest = goals[0]
+	fs.dist = fs.exp.calcDist(goals[0])
 
 	fh := &fsHeap{fs}
 	heap.Init(fh)
@@ -307,16 +394,29 @@ func (fs *fieldState) minPathBFS(maxTime int, dest goal) (*fieldState, bool) {
 	nextFh := &fsHeap{}
 	heap.Init(nextFh)
 
+	// states behind floor are dropped: once at the start or the exit, where
+	// blizzards never reach, the expedition can wait for any later arrival
+	var floor int
 	for len(*fh) > 0 {
 		for len(*fh) > 0 {
 			ns := heap.Pop(fh).(*fieldState)
+			if ns.leg < floor {
+				continue
+			}
 
-			if ns.exp.x == dest.x && ns.exp.y == dest.y {
+			if !s.reached(ns, goals) {
+				// already on the next goal from the same spot
+				continue
+			}
+			if ns.leg == len(goals) {
 				return ns, true
 			}
+			if ns.leg > floor && s.f.isEdge(goals[ns.leg-1]) {
+				floor = ns.leg
+			}
 
 			if ns.dist < maxTime {
-				nextFh.pushAll(ns.nextStates(dest))
+				nextFh.pushAll(s.nextStates(ns, ns.dest))
 			}
 		}
 
@@ -330,14 +430,29 @@ func (fs *fieldState) minPathBFS(maxTime int, dest goal) (*fieldState, bool) {
 	return nil, false
 }
 
+// reached moves fs on to the next goals for as long as it stands on the one
+// it heads to, len(goals) once through all of them. It returns false if
+// the search was already there on the new leg.
+func (s *search) reached(fs *fieldState, goals []goal) bool {
+	for fs.leg < len(goals) && fs.exp.x == fs.dest.x && fs.exp.y == fs.dest.y {
+		fs.leg++
+		if fs.leg == len(goals) {
+			return true
+		}
+		fs.dest = goals[fs.leg]
+		fs.dist = fs.exp.calcDist(fs.dest)
+		if !s.visit(fs) {
+			return false
+		}
+	}
+	return true
+}
+
 type goal struct {
 	x, y int
 }
 
-func processFile(fileName string, goalNum int) (int, error) {
-
-	fmt.Println("Processing ", fileName)
-
+func readField(fileName string) (*field, error) {
 	matrix := []string{}
 	err := input.ReadFileLines(fileName, func(line string) error {
 		matrix = append(matrix, line)
@@ -349,12 +464,29 @@ func processFile(fileName string, goalNum int) (int, error) {
 		return nil
 	})
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	f := NewField(matrix)
 	if f.s < 0 || f.e < 0 {
-		return 0, errors.New(fmt.Sprint("Start or end not found", f.s, f.e))
+		return nil, errors.New(fmt.Sprint("Start or end not found", f.s, f.e))
+	}
+	return f, nil
+}
+
+// processFile searches the trip through the first goalNum goals. With
+// precompute, the blizzards are looked up in the precomputed occupancy
+// rather than the rings.
+func processFile(fileName string, goalNum int, precompute bool) (int, error) {
+
+	fmt.Println("Processing ", fileName)
+
+	f, err := readField(fileName)
+	if err != nil {
+		return 0, err
+	}
+	if precompute {
+		f.precompute()
 	}
 
 	goals := []goal{
@@ -366,17 +498,16 @@ func processFile(fileName string, goalNum int) (int, error) {
 		return 0, errors.New(fmt.Sprint("invalid number of goals ", goalNum))
 	}
 
-	fs := NewFieldState(f, goals[0])
-	res, found := fs.minPathGoals(math.MaxInt, goals[0:goalNum])
-	if !found {
-		return 0, errors.New("path not found")
+	path, err := f.findPath(goals[0:goalNum])
+	if err != nil {
+		return 0, err
 	}
 
-	return res.exp.t, nil
+	return path[len(path)-1].exp.t, nil
 }
 
 func main() {
-	res, err := processFile("data/part_one.txt", 1)
+	res, err := processFile("data/part_one.txt", 1, true)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -385,7 +516,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals(10, res, "")
 
-	res, err = processFile("data/part_one_two.txt", 1)
+	res, err = processFile("data/part_one_two.txt", 1, true)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -394,7 +525,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals(18, res, "")
 
-	res, err = processFile("data/input.txt", 1)
+	res, err = processFile("data/input.txt", 1, true)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -403,7 +534,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals(290, res, "")
 
-	res, err = processFile("data/part_one.txt", 3)
+	res, err = processFile("data/part_one.txt", 3, true)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -412,7 +543,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals(30, res, "")
 
-	res, err = processFile("data/part_one_two.txt", 3)
+	res, err = processFile("data/part_one_two.txt", 3, true)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -421,7 +552,7 @@ func main() {
 	fmt.Println("=================")
 	assert.Equals(54, res, "")
 
-	res, err = processFile("data/input.txt", 3)
+	res, err = processFile("data/input.txt", 3, true)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"kfet.org/aoc_common/calc"
 )
 
 func TestRight(t *testing.T) {
+	t.Parallel()
+
 	rbr := &rightBlizzardRing{
 		m: map[int]struct{}{
 			1: {},
@@ -52,6 +60,8 @@ func TestRight(t *testing.T) {
 }
 
 func TestLeft(t *testing.T) {
+	t.Parallel()
+
 	br := &blizzardRing{
 		m: map[int]struct{}{
 			2: {},
@@ -83,3 +93,191 @@ func TestLeft(t *testing.T) {
 	assert.False(t, br.hasBlizzard(2, 1))
 	assert.False(t, br.hasBlizzard(3, 1))
 }
+
+func TestProcessFile(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		fileName string
+		goalNum  int
+		want     int
+	}{
+		{"data/part_one.txt", 1, 10},
+		{"data/part_one_two.txt", 1, 18},
+		{"data/input.txt", 1, 290},
+		{"data/part_one.txt", 3, 30},
+		{"data/part_one_two.txt", 3, 54},
+		{"data/input.txt", 3, 842},
+	} {
+		for _, precompute := range []bool{false, true} {
+			tc, precompute := tc, precompute
+			t.Run(fmt.Sprint(tc.fileName, " ", tc.goalNum, " ", precompute), func(t *testing.T) {
+				t.Parallel()
+
+				res, err := processFile(tc.fileName, tc.goalNum, precompute)
+				assert.Nil(t, err)
+				assert.Equal(t, tc.want, res)
+			})
+		}
+	}
+}
+
+func TestConcurrentSearches(t *testing.T) {
+	t.Parallel()
+
+	f := NewField([]string{
+		"#.######",
+		"#>>.<^<#",
+		"#.<..<<#",
+		"#>v.><>#",
+		"#<^v^^>#",
+		"######.#",
+	})
+	exit := goal{f.e, f.h}
+
+	// the searches share the field, but not their visited positions
+	results := make([]int, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, found := NewSearch(f).minPathGoals(NewFieldState(f, exit), math.MaxInt, []goal{exit})
+			if found {
+				results[i] = res.exp.t
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, res := range results {
+		assert.Equal(t, 18, res)
+	}
+}
+
+func TestOccupancy(t *testing.T) {
+	t.Parallel()
+
+	f, err := readField("data/input.txt")
+	assert.Nil(t, err)
+	pf, err := readField("data/input.txt")
+	assert.Nil(t, err)
+	pf.precompute()
+
+	for tm := 0; tm < f.lcd+1; tm++ {
+		for y := 0; y < f.h; y++ {
+			for x := 0; x < f.w; x++ {
+				p := pos{x, y, tm}
+				if f.hasBlizzard(p) != pf.hasBlizzard(p) {
+					t.Fatalf("occupancy differs at %v", p)
+				}
+			}
+		}
+	}
+}
+
+func benchmarkTrip(b *testing.B, precompute bool) {
+	f, err := readField("data/input.txt")
+	assert.Nil(b, err)
+	if precompute {
+		f.precompute()
+	}
+	goals := []goal{{f.e, f.h}, {f.s, -1}, {f.e, f.h}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res, found := NewSearch(f).minPathGoals(NewFieldState(f, goals[0]), math.MaxInt, goals)
+		assert.True(b, found)
+		assert.Equal(b, 842, res.exp.t)
+	}
+}
+
+func BenchmarkRings(b *testing.B) {
+	benchmarkTrip(b, false)
+}
+
+func BenchmarkOccupancy(b *testing.B) {
+	benchmarkTrip(b, true)
+}
+
+func TestItinerary(t *testing.T) {
+	t.Parallel()
+
+	f, err := readField("data/part_one_two.txt")
+	assert.Nil(t, err)
+	exit, start := goal{f.e, f.h}, goal{f.s, -1}
+
+	path, err := f.findPath([]goal{exit, start, exit})
+	assert.Nil(t, err)
+	assert.Equal(t, 55, len(path))
+
+	// one move or wait per minute, never into a blizzard
+	for i, fs := range path {
+		assert.Equal(t, i, fs.exp.t)
+		assert.True(t, fs.exp.y < 0 || fs.exp.y >= f.h || !f.hasBlizzard(fs.exp))
+		if i > 0 {
+			prev := path[i-1].exp
+			assert.LessOrEqual(t, calc.TaxiCab(prev.x, prev.y, fs.exp.x, fs.exp.y), 1)
+		}
+	}
+	assert.Equal(t, pos{f.e, f.h, 18}, path[18].exp)
+
+	// a detour through the middle of the valley
+	mid := goal{2, 2}
+	path, err = f.findPath([]goal{mid, exit})
+	assert.Nil(t, err)
+	visited := false
+	for _, fs := range path {
+		if fs.exp.x == mid.x && fs.exp.y == mid.y {
+			visited = true
+		}
+	}
+	assert.True(t, visited)
+	assert.LessOrEqual(t, 18, path[len(path)-1].exp.t)
+
+	_, err = f.findPath([]goal{{-1, 0}})
+	assert.NotNil(t, err)
+	_, err = f.findPath(nil)
+	assert.NotNil(t, err)
+}
+
+func TestItineraryDeadEnd(t *testing.T) {
+	t.Parallel()
+
+	// the earliest arrival at the waypoint has no way on to the exit
+	f, err := readField("data/input.txt")
+	assert.Nil(t, err)
+	f.precompute()
+	mid, exit := goal{43, 7}, goal{f.e, f.h}
+	assert.Equal(t, goal{119, 25}, exit)
+
+	path, err := f.findPath([]goal{mid, exit})
+	assert.Nil(t, err)
+	assert.Equal(t, 290, path[len(path)-1].exp.t)
+	visited := false
+	for _, fs := range path {
+		if fs.exp.x == mid.x && fs.exp.y == mid.y {
+			visited = true
+		}
+	}
+	assert.True(t, visited)
+
+	// the same without the precomputed occupancy
+	f, err = readField("data/input.txt")
+	assert.Nil(t, err)
+	path, err = f.findPath([]goal{mid, exit})
+	assert.Nil(t, err)
+	assert.Equal(t, 290, path[len(path)-1].exp.t)
+}
+
+func TestWriteFrames(t *testing.T) {
+	t.Parallel()
+
+	path, err := processItinerary("data/part_one.txt", []goal{{4, 5}}, true)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeFrames(&buf, path))
+	assert.Equal(t, len(path), strings.Count(buf.String(), "== Minute "))
+	assert.Contains(t, buf.String(), "== Minute 10 ==")
+}
@@ -1,59 +1,97 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"math"
+	"io"
+	"math/big"
+	"os"
 
 	"github.com/samber/lo"
 	"kfet.org/aoc_common/assert"
 	"kfet.org/aoc_common/input"
+	"kfet.org/aoc_common/numeral"
 )
 
 type digit int
 type number []digit
 
-func (n number) toDecimal() int {
-	var res int
-	l := len(n) - 1
+// toNumeral returns the number as a numeral.SNAFU number, which does the
+// arithmetic. It fails on digits out of the -2..2 range.
+func (n number) toNumeral() (numeral.Number, error) {
+	digits := make([]numeral.Digit, len(n))
 	for i, d := range n {
-		p := math.Pow(5, float64(l-i))
-		num := int(d) * int(p)
-		res += num
+		digits[i] = numeral.Digit(d)
+	}
+	return numeral.SNAFU.FromDigits(digits)
+}
+
+func fromNumeral(v numeral.Number) number {
+	digits := v.Digits()
+	res := make(number, len(digits))
+	for i, d := range digits {
+		res[i] = digit(d)
 	}
 	return res
 }
 
-func NumberFromString(line string) number {
-	return lo.Map([]byte(line), func(item byte, index int) digit {
+// toBig converts exactly, however long the number is.
+func (n number) toBig() (*big.Int, error) {
+	v, err := n.toNumeral()
+	if err != nil {
+		return nil, err
+	}
+	return v.Big(), nil
+}
+
+func NumberFromBig(n *big.Int) number {
+	return fromNumeral(numeral.SNAFU.FromBig(n))
+}
+
+func parseNumber(line string) (number, error) {
+	if len(line) == 0 {
+		return nil, errors.New("empty number")
+	}
+	res := make(number, len(line))
+	for i, item := range []byte(line) {
 		switch item {
 		case '-':
-			return -1
+			res[i] = -1
 		case '=':
-			return -2
-		case '2':
-			return 2
-		case '1':
-			return 1
-		case '0':
-			return 0
+			res[i] = -2
+		case '2', '1', '0':
+			res[i] = digit(item - '0')
+		default:
+			return nil, fmt.Errorf("unknown digit %q in %s", item, line)
 		}
-
-		panic(fmt.Sprint("unknown item", item))
-	})
+	}
+	return res, nil
 }
 
-func NumberFromDecimal(n int) number {
-	fiv := n % 5
-	if fiv > 2 {
-		fiv = fiv - 5
+func NumberFromString(line string) number {
+	n, err := parseNumber(line)
+	if err != nil {
+		panic(err)
 	}
+	return n
+}
 
-	if n-fiv == 0 {
-		return number{digit(fiv)}
+// Add sums the numbers column by column, with the carries, however long
+// they are.
+func (n number) Add(o number) (number, error) {
+	a, err := n.toNumeral()
+	if err != nil {
+		return nil, err
+	}
+	b, err := o.toNumeral()
+	if err != nil {
+		return nil, err
 	}
+	return fromNumeral(a.Add(b)), nil
+}
 
-	pref := NumberFromDecimal((n - fiv) / 5)
-	return append(pref, digit(fiv))
+func NumberFromDecimal(n int) number {
+	return fromNumeral(numeral.SNAFU.FromInt(int64(n)))
 }
 
 func (n number) String() string {
@@ -73,18 +111,40 @@ func (n number) String() string {
 	}, "")
 }
 
-func processFile(fileName string) (string, error) {
-	var resInt int
-	err := input.ReadFileLines(fileName, func(line string) error {
-		n := NumberFromString(line)
-		resInt += n.toDecimal()
+// sumLines adds up the numbers, one per line, as they are read.
+func sumLines(r io.Reader) (number, error) {
+	sum := numeral.SNAFU.Zero()
+	err := input.ReadLines(r, func(line string) error {
+		n, err := numeral.SNAFU.Parse(line)
+		if err != nil {
+			return err
+		}
+		sum = sum.Add(n)
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return fromNumeral(sum), nil
+}
+
+func processFile(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	sum, err := sumLines(file)
+	if err != nil {
+		return "", err
+	}
+	dec, err := sum.toBig()
 	if err != nil {
 		return "", err
 	}
-	fmt.Println(resInt)
-	return NumberFromDecimal(resInt).String(), nil
+	fmt.Println(dec)
+	return sum.String(), nil
 }
 
 func main() {
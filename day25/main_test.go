@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessFile(t *testing.T) {
+	res, err := processFile("data/part_one.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "2=-1=0", res)
+
+	res, err = processFile("data/input.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "2=1-=02-21===-21=200", res)
+}
+
+func TestAdd(t *testing.T) {
+	for _, tc := range []struct {
+		a, b, want string
+	}{
+		{"0", "0", "0"},
+		{"1", "1", "2"},
+		{"2", "1", "1="},
+		{"1=", "-", "2"},
+		{"-", "1", "0"},
+		{"2=", "2=", "1=1"},
+		{"1=-0-2", "12111", "1-111="},
+	} {
+		sum, err := NumberFromString(tc.a).Add(NumberFromString(tc.b))
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, sum.String())
+		sum, err = NumberFromString(tc.b).Add(NumberFromString(tc.a))
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, sum.String())
+	}
+
+	// digits out of range fail instead of panicking
+	_, err := number{1, 3}.Add(number{1})
+	assert.NotNil(t, err)
+	_, err = number{1}.toBig()
+	assert.Nil(t, err)
+	_, err = number{-3}.toBig()
+	assert.NotNil(t, err)
+}
+
+func TestBigSum(t *testing.T) {
+	// numbers far beyond int64, the sum must stay exact
+	rnd := rand.New(rand.NewSource(25))
+	want := new(big.Int)
+	var lines []string
+	for i := 0; i < 200; i++ {
+		var sb strings.Builder
+		sb.WriteByte("12"[rnd.Intn(2)])
+		for j := 0; j < 40+rnd.Intn(20); j++ {
+			sb.WriteByte("=-012"[rnd.Intn(5)])
+		}
+		lines = append(lines, sb.String())
+		n, err := NumberFromString(sb.String()).toBig()
+		assert.Nil(t, err)
+		want.Add(want, n)
+	}
+
+	sum, err := sumLines(strings.NewReader(strings.Join(lines, "\n")))
+	assert.Nil(t, err)
+	got, err := sum.toBig()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, want.Cmp(got))
+	assert.Equal(t, NumberFromBig(want).String(), sum.String())
+
+	_, err = sumLines(strings.NewReader("1=\n12x\n"))
+	assert.NotNil(t, err)
+}
+
+func TestFromDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		dec   int
+		snafu string
+	}{
+		{0, "0"},
+		{3, "1="},
+		{2022, "1=11-2"},
+		{314159265, "1121-1110-1=0"},
+		{-1, "-"},
+		{-3, "-2"},
+		{-2022, "-2--1="},
+	} {
+		assert.Equal(t, tc.snafu, NumberFromDecimal(tc.dec).String())
+		n, err := NumberFromString(tc.snafu).toBig()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(tc.dec), n.Int64())
+	}
+}